@@ -0,0 +1,91 @@
+package alog
+
+import (
+	"time"
+
+	"github.com/vosst/alog/quirk"
+)
+
+const (
+	loggerEntryV2HeaderSize = 24                     // Size of the logger_entry header in ABI version 2
+	abiProbeTimeout         = 100 * time.Millisecond // Maximum wait for an entry when probing the ABI
+)
+
+// DetectAbiExtension determines the LoggerAbiExtension matching the kernel
+// logger of the running device, probing the device of the log identified by
+// id and consulting the quirks registered in package quirk for the device's
+// properties. The result can be passed to NewLoggerReader.
+//
+// Returns a nil LoggerAbiExtension if the kernel logger only supports ABI version 1.
+// Returns an error if accessing the kernel logger fails.
+func DetectAbiExtension(id LogId) (LoggerAbiExtension, error) {
+	dev, err := OpenLoggerDevice(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer dev.Close()
+
+	// Lacking properties, we rely on the probed header size alone.
+	props, _ := DeviceProperties()
+
+	return DetectAbiExtensionForDevice(dev, props)
+}
+
+// DetectAbiExtensionForDevice determines the LoggerAbiExtension matching dev
+// on a device with properties props:
+//   - If dev does not support versioning or the first entry read from dev
+//     carries the v1 header, nil is returned.
+//   - If the first entry read from dev carries the standard v2 header, a
+//     LoggerAbiV2Extension is returned.
+//   - Otherwise, if a quirk registered in package quirk matches props and
+//     consumes the additional header bytes of the first entry (if any), the
+//     LoggerAbiExtension for the quirk is returned.
+//   - Otherwise, a LoggerAbiV2Extension is returned, skipping unknown header bytes.
+//
+// dev is switched to ABI version 2 and its first entry, if any, is consumed.
+//
+// Returns an error if switching dev to ABI version 2 fails.
+func DetectAbiExtensionForDevice(dev LoggerDevice, props map[string]string) (LoggerAbiExtension, error) {
+	if _, err := dev.Version(); err != nil {
+		return nil, nil
+	}
+
+	if err := dev.SetVersion(2); err != nil {
+		return nil, err
+	}
+
+	hdrSize := -1
+
+	buf := make([]byte, maxEntrySize, maxEntrySize)
+	dev.SetDeadline(time.Now().Add(abiProbeTimeout))
+	if n, err := dev.Read(buf); err == nil && n >= loggerEntryHeaderSize {
+		hdrSize, _ = loggerRecordSize(buf[:n])
+	}
+	dev.SetDeadline(time.Time{})
+
+	// A known header size takes precedence over quirks matched by properties alone.
+	switch hdrSize {
+	case loggerEntryHeaderSize:
+		return nil, nil
+	case loggerEntryV2HeaderSize:
+		return LoggerAbiV2Extension{}, nil
+	}
+
+	vendorSize := -1
+	if hdrSize > loggerEntryV2HeaderSize {
+		vendorSize = hdrSize - loggerEntryV2HeaderSize
+	}
+
+	if q, ok := quirk.Lookup(props, vendorSize); ok {
+		return NewQuirkAbiExtension(q), nil
+	}
+
+	return LoggerAbiV2Extension{}, nil
+}
+
+// NewQuirkAbiExtension returns the LoggerAbiExtension for a device affected
+// by q, chaining the quirk's extension with a LoggerAbiV2Extension.
+func NewQuirkAbiExtension(q quirk.Quirk) LoggerAbiExtension {
+	return ChainedLoggerAbiExtension{[]LoggerAbiExtension{q.Extension, LoggerAbiV2Extension{}}}
+}
@@ -0,0 +1,80 @@
+package alog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vosst/alog/quirk"
+)
+
+var mx4Properties = map[string]string{"ro.product.model": "MX4", "ro.hardware": "mt6595"}
+
+func TestDetectAbiExtensionReturnsNilForAbiV1(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), mx4Properties)
+	require.NoError(t, err)
+	assert.Nil(t, ext)
+}
+
+func TestDetectAbiExtensionPrefersV1HeaderOverQuirk(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, V1Headers: true})
+	fl.Append(LogIdMain, testEntry)
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), mx4Properties)
+	require.NoError(t, err)
+	assert.Nil(t, ext)
+}
+
+func TestDetectAbiExtensionPrefersStandardV2Header(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2})
+	fl.Append(LogIdMain, testEntry)
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), mx4Properties)
+	require.NoError(t, err)
+	assert.Equal(t, LoggerAbiV2Extension{}, ext)
+}
+
+func TestDetectAbiExtensionPicksQuirkForExtendedHeader(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, Euid: 1000, VendorHeader: []byte{0x10, 0x0e, 0, 0}})
+	fl.Append(LogIdMain, testEntry)
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), mx4Properties)
+	require.NoError(t, err)
+	assert.Equal(t, ChainedLoggerAbiExtension{[]LoggerAbiExtension{quirk.MeizuMx4LoggerAbiExtension{}, LoggerAbiV2Extension{}}}, ext)
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), ext)
+	require.NoError(t, err)
+
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, int32(3600), entry.Ext[ExtTimeZone])
+	assert.Equal(t, uint32(1000), entry.Ext[ExtEuid])
+}
+
+func TestDetectAbiExtensionPicksQuirkForEmptyLog(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2})
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), mx4Properties)
+	require.NoError(t, err)
+	assert.IsType(t, ChainedLoggerAbiExtension{}, ext)
+}
+
+func TestDetectAbiExtensionIgnoresQuirkWithDifferentHeaderSize(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, VendorHeader: []byte{1, 2, 3, 4, 5, 6, 7, 8}})
+	fl.Append(LogIdMain, testEntry)
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), mx4Properties)
+	require.NoError(t, err)
+	assert.Equal(t, LoggerAbiV2Extension{}, ext)
+}
+
+func TestDetectAbiExtensionFallsBackToV2ForUnknownDevices(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, VendorHeader: []byte{1, 2, 3, 4}})
+	fl.Append(LogIdMain, testEntry)
+
+	ext, err := DetectAbiExtensionForDevice(fl.Open(LogIdMain), map[string]string{"ro.product.model": "Nexus 4"})
+	require.NoError(t, err)
+	assert.Equal(t, LoggerAbiV2Extension{}, ext)
+}
@@ -16,6 +16,11 @@
 //
 //	alog.D(alog.Main, "tag", "message")
 //
+// On hosts without Android's logging facilities, the global Writers fall back to
+// stderr. The fallback is configurable:
+//
+//	alog.SetFallback(alog.Discard)
+//
 // Finally, applications can leverage the interface Writer and its implementation LoggerWriter
 // to write to the Android logging facilities. The respective types and functions are meant to
 // be used for integration purposes with other logging frameworks.
@@ -35,11 +40,14 @@ package alog
 
 import "log"
 
+// The global Writers connect to Android's logging facilities on first use.
+// If connecting fails, their Err method reports the reason and messages are
+// sent to the Writer configured via SetFallback instead.
 var (
-	Main, _   = NewLoggerWriter(LogIdMain)   // Global Writer for accessing log Main.
-	Radio, _  = NewLoggerWriter(LogIdRadio)  // Global Writer for accessing log Radio.
-	Events, _ = NewLoggerWriter(LogIdEvents) // Global Writer for accessing log Events.
-	System, _ = NewLoggerWriter(LogIdSystem) // Global Writer for accessing log System.
+	Main   = NewLazyWriter(LogIdMain)   // Global Writer for accessing log Main.
+	Radio  = NewLazyWriter(LogIdRadio)  // Global Writer for accessing log Radio.
+	Events = NewLazyWriter(LogIdEvents) // Global Writer for accessing log Events.
+	System = NewLazyWriter(LogIdSystem) // Global Writer for accessing log System.
 )
 
 type ioWriterWrapper struct {
@@ -0,0 +1,118 @@
+package alog
+
+import (
+	"io"
+	"time"
+)
+
+// A BinaryReader implements Reader, parsing a stream of logger_entry records
+// as written by logcat -B or FormatBinary.
+type BinaryReader struct {
+	r      io.Reader    // The stream we read records from
+	parser recordParser // Parses records, honouring the ABI extension
+	buf    []byte       // Buffer for reading individual records
+}
+
+// NewBinaryReader returns a BinaryReader reading records from r. If abiExtension
+// is not nil it is used to parse header fields beyond the v1 ABI.
+func NewBinaryReader(r io.Reader, abiExtension LoggerAbiExtension) *BinaryReader {
+	return &BinaryReader{r: r, parser: recordParser{abiExtension: abiExtension, id: LogIdUnknown}, buf: make([]byte, maxEntrySize, maxEntrySize)}
+}
+
+// Close closes the underlying stream if it implements io.Closer.
+func (self *BinaryReader) Close() error {
+	if c, ok := self.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// SetDeadline is a noop for BinaryReader.
+func (self *BinaryReader) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetTagTable makes self intern the tags of all entries read in tags.
+// Passing nil disables interning.
+func (self *BinaryReader) SetTagTable(tags *TagTable) {
+	self.parser.tags = tags
+}
+
+// SetRawMessages makes self preserve the exact message bytes of all entries
+// read in Entry.RawMessage, including leading and trailing whitespace and
+// invalid UTF-8 sequences. Entry.Message remains available as a trimmed view.
+func (self *BinaryReader) SetRawMessages(raw bool) {
+	self.parser.raw = raw
+}
+
+// SetLenient makes self skip malformed records instead of returning a
+// *ParseError, counting them in Skipped. Records announcing an invalid header
+// size or a size beyond the maximum entry size are skipped as a whole, such
+// that reading resumes with the next record.
+func (self *BinaryReader) SetLenient(lenient bool) {
+	self.parser.lenient = lenient
+}
+
+// Skipped returns the number of malformed records skipped by self in lenient mode.
+func (self *BinaryReader) Skipped() uint64 {
+	return self.parser.skipped
+}
+
+// ReadNext reads the next record from the underlying stream.
+//
+// Returns io.EOF at the end of the stream.
+// Returns io.ErrUnexpectedEOF if the stream ends within a record.
+// Returns a *ParseError if a record is malformed, unless self is lenient.
+// Returns an error if reading fails.
+func (self *BinaryReader) ReadNext() (*Entry, error) {
+	entry := &Entry{}
+	if err := self.ReadInto(entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// ReadInto is like ReadNext but reads the next record into entry, overwriting
+// all of its fields.
+//
+// Returns the same errors as ReadNext.
+func (self *BinaryReader) ReadInto(entry *Entry) error {
+	for {
+		if err := self.readRecord(entry); !self.parser.skip(err) {
+			return err
+		}
+	}
+}
+
+// readRecord reads and parses a single record from the underlying stream.
+func (self *BinaryReader) readRecord(entry *Entry) error {
+	if n, err := io.ReadFull(self.r, self.buf[:4]); err == io.ErrUnexpectedEOF {
+		return self.parser.fail(ErrShortHeader, err, self.buf[:n], n, entry)
+	} else if err != nil {
+		return err
+	}
+
+	hdrSize, size := loggerRecordSize(self.buf)
+	if hdrSize < loggerEntryHeaderSize || size > len(self.buf) {
+		if _, err := io.CopyN(io.Discard, self.r, int64(size-4)); err != nil && err != io.EOF {
+			return err
+		}
+
+		kind := ErrBadLength
+		if hdrSize < loggerEntryHeaderSize {
+			kind = ErrShortHeader
+		}
+		return self.parser.fail(kind, nil, self.buf[:4], size, entry)
+	}
+
+	if _, err := io.ReadFull(self.r, self.buf[4:size]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	return self.parser.parse(self.buf[:size], entry)
+}
@@ -0,0 +1,61 @@
+package alog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryReaderReadsEntriesWrittenInFormatBinary(t *testing.T) {
+	buf := &bytes.Buffer{}
+	FormatBinary.Render(buf, &testEntry)
+	FormatBinary.Render(buf, &testEntry)
+
+	br := NewBinaryReader(buf, nil)
+
+	for i := 0; i < 2; i++ {
+		entry, err := br.ReadNext()
+		require.NoError(t, err)
+		assert.Equal(t, testEntry, *entry)
+	}
+
+	_, err := br.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBinaryReaderHandsExtraHeaderToAbiExtension(t *testing.T) {
+	record := appendLoggerEntry(nil, &testEntry)
+	record = append(record[:loggerEntryHeaderSize], append([]byte{42, 0, 0, 0}, record[loggerEntryHeaderSize:]...)...)
+	record[2] = loggerEntryHeaderSize + 4
+
+	entry, err := NewBinaryReader(bytes.NewReader(record), LoggerAbiV2Extension{}).ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(42), entry.Ext[ExtEuid])
+	assert.Equal(t, testEntry.Message, entry.Message)
+}
+
+func TestBinaryReaderRejectsTruncatedRecords(t *testing.T) {
+	record := appendLoggerEntry(nil, &testEntry)
+
+	_, err := NewBinaryReader(bytes.NewReader(record[:2]), nil).ReadNext()
+	assert.Error(t, err)
+
+	_, err = NewBinaryReader(bytes.NewReader(record[:len(record)-1]), nil).ReadNext()
+	assert.Error(t, err)
+}
+
+func TestBinaryReaderPreservesRawMessages(t *testing.T) {
+	entry := testEntry
+	entry.Message = "  indented\n"
+
+	br := NewBinaryReader(bytes.NewReader(appendLoggerEntry(nil, &entry)), nil)
+	br.SetRawMessages(true)
+
+	read, err := br.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("  indented\n"), read.RawMessage)
+	assert.Equal(t, "indented", read.Message)
+}
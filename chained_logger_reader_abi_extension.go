@@ -1,6 +1,9 @@
 package alog
 
-import "io"
+import (
+	"io"
+	"reflect"
+)
 
 // A ChainedLoggerAbiExtension is a slice of LoggerAbiExtensions,
 // forwarding calls to Prepare and Read to the individual extensions.
@@ -27,3 +30,16 @@ func (self ChainedLoggerAbiExtension) Read(reader io.Reader) (map[string]interfa
 
 	return result, nil
 }
+
+// Schema merges the schemas of all extensions known to self.
+func (self ChainedLoggerAbiExtension) Schema() map[string]reflect.Type {
+	result := make(map[string]reflect.Type)
+
+	for _, ext := range self.Extensions {
+		for k, v := range ext.Schema() {
+			result[k] = v
+		}
+	}
+
+	return result
+}
@@ -2,9 +2,12 @@ package alog
 
 import (
 	"io"
+	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/vosst/alog/quirk"
 )
 
 type MockLoggerAbiExtension struct {
@@ -16,6 +19,10 @@ func (self *MockLoggerAbiExtension) Read(reader io.Reader) (map[string]interface
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
+func (self *MockLoggerAbiExtension) Schema() map[string]reflect.Type {
+	return nil
+}
+
 type MockReader struct {
 	mock.Mock
 }
@@ -42,3 +49,12 @@ func TestChainedLoggerAbiExtensionsCallsIntoAllExtensions(t *testing.T) {
 	mle1.AssertExpectations(t)
 	mle2.AssertExpectations(t)
 }
+
+func TestChainedLoggerAbiExtensionMergesSchemas(t *testing.T) {
+	ch := ChainedLoggerAbiExtension{[]LoggerAbiExtension{quirk.MeizuMx4LoggerAbiExtension{}, LoggerAbiV2Extension{}}}
+
+	assert.Equal(t, map[string]reflect.Type{
+		ExtTimeZone: reflect.TypeOf(int32(0)),
+		ExtEuid:     reflect.TypeOf(uint32(0)),
+	}, ch.Schema())
+}
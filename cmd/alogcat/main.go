@@ -0,0 +1,591 @@
+// Command alogcat reads, filters and renders entries from Android's logging
+// facilities, mirroring the options of logcat:
+//
+//	alogcat [options] [filterspecs]
+//
+// Entries are read from the kernel logger if /dev/alog is available and from
+// logd otherwise, or from a file written by alogcat -v binary if -i is given.
+// Filterspecs follow logcat's syntax tag[:priority] and default to
+// $ANDROID_LOG_TAGS.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vosst/alog"
+	"github.com/vosst/alog/quirk"
+)
+
+// dumpTimeout bounds the wait for further entries from the kernel logger
+// when dumping, mirroring logcat's non-blocking reads.
+const dumpTimeout = 100 * time.Millisecond
+
+// buffersFlag collects the logs given by multiple -b options.
+type buffersFlag []alog.LogId
+
+func (self *buffersFlag) String() string {
+	names := make([]string, 0, len(*self))
+	for _, id := range *self {
+		names = append(names, id.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func (self *buffersFlag) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		if name == "all" {
+			*self = append(*self, alog.LogIdMain, alog.LogIdRadio, alog.LogIdEvents, alog.LogIdSystem, alog.LogIdCrash)
+			continue
+		}
+
+		id, err := alog.ParseLogId(name)
+		if err != nil {
+			return err
+		}
+		*self = append(*self, id)
+	}
+	return nil
+}
+
+var (
+	buffers     buffersFlag
+	format      = flag.String("v", "threadtime", "Output `format`: brief, process, tag, thread, raw, time, threadtime, long or binary, optionally followed by comma-separated modifiers uid and process")
+	dump        = flag.Bool("d", false, "Dump the logs and exit")
+	clear       = flag.Bool("c", false, "Clear the logs and exit")
+	sizes       = flag.Bool("g", false, "Print the size of the logs and exit")
+	tail        = flag.Int("t", 0, "Print the most recent `count` entries and exit")
+	tailFollow  = flag.Int("T", 0, "Print the most recent `count` entries and keep on reading")
+	file        = flag.String("f", "", "Write to `file` instead of stdout")
+	rotateKb    = flag.Int("r", 0, "Rotate the output file every `kbytes`, requires -f")
+	rotateCount = flag.Int("n", 4, "Keep `count` rotated files, requires -r")
+	pid         = flag.Int("pid", 0, "Only print entries logged by `pid`")
+	pattern     = flag.String("regex", "", "Only print entries whose message matches `expr`")
+	input       = flag.String("i", "", "Read entries in binary format from `file` instead")
+	last        = flag.Bool("L", false, "Print the logs of the previous boot recovered from pstore and exit")
+	source      = flag.String("source", "auto", "Read from `source`: auto, kernel or logd")
+	logdDir     = flag.String("logd", alog.DefaultLogd.Dir, "Talk to the logd sockets in `dir`")
+	syslogUrl   = flag.String("syslog", "", "Forward entries in RFC 5424 format to the syslog server at `url`, e.g., udp://host:514, tcp://host:601 or unixgram:///dev/log")
+	jsonOut     = flag.Bool("json", false, "Print entries as newline-delimited JSON instead of rendering them")
+	quirkName   = flag.String("quirk", "", "Read kernel logs assuming the vendor `quirk` instead of detecting the ABI, list prints all known quirks")
+)
+
+func init() {
+	flag.Var(&buffers, "b", "Read from `buffer`: main, radio, events, system, crash, kernel or all but kernel; may be repeated")
+}
+
+// A sink consumes the entries that pass all filters.
+type sink interface {
+	WriteEntry(entry *alog.Entry) error
+	Close() error
+}
+
+// A textSink renders entries to stdout.
+type textSink struct {
+	format    alog.Format
+	modifiers alog.Modifier
+	resolver  *alog.ProcessResolver
+	w         *bufio.Writer
+	buf       bytes.Buffer
+}
+
+func (self *textSink) WriteEntry(entry *alog.Entry) error {
+	self.buf.Reset()
+	self.format.RenderWith(&self.buf, entry, self.modifiers, self.resolver)
+	if _, err := self.w.Write(self.buf.Bytes()); err != nil {
+		return err
+	}
+	return self.w.Flush()
+}
+
+func (self *textSink) Close() error {
+	return self.w.Flush()
+}
+
+// parseFormat parses a format optionally followed by comma-separated modifiers, e.g., threadtime,uid.
+func parseFormat(s string) (alog.Format, alog.Modifier, error) {
+	names := strings.Split(s, ",")
+
+	f, err := alog.ParseFormat(names[0])
+	if err != nil {
+		return f, 0, err
+	}
+
+	var modifiers alog.Modifier
+	for _, name := range names[1:] {
+		m, err := alog.ParseModifier(name)
+		if err != nil {
+			return f, 0, err
+		}
+		modifiers |= m
+	}
+
+	return f, modifiers, nil
+}
+
+// checkFlags rejects flags that would be silently ignored.
+func checkFlags() error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if set["r"] && !set["f"] {
+		return fmt.Errorf("-r requires -f")
+	}
+
+	if set["n"] && !set["r"] {
+		return fmt.Errorf("-n requires -r")
+	}
+
+	// -syslog, -json and -f select mutually exclusive outputs.
+	var outputs []string
+	if *syslogUrl != "" {
+		outputs = append(outputs, "-syslog")
+	}
+	if *jsonOut {
+		outputs = append(outputs, "-json")
+	}
+	if *file != "" {
+		outputs = append(outputs, "-f")
+	}
+
+	if len(outputs) > 1 {
+		return fmt.Errorf("%s select conflicting outputs", strings.Join(outputs, " and "))
+	}
+
+	return nil
+}
+
+// useLogd returns true if we should read from logd instead of the kernel logger.
+func useLogd() (bool, error) {
+	switch *source {
+	case "kernel":
+		return false, nil
+	case "logd":
+		return true, nil
+	case "auto":
+		_, err := os.Stat("/dev/alog")
+		return err != nil && alog.DefaultLogd.Available(), nil
+	}
+
+	return false, fmt.Errorf("Unknown source: %s", *source)
+}
+
+func clearLogs(logd bool) error {
+	for _, id := range buffers {
+		var err error
+		if logd {
+			err = alog.DefaultLogd.Clear(id)
+		} else {
+			err = alog.ClearLoggerLog(id)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printSizes(logd bool) error {
+	for _, id := range buffers {
+		var size, used int
+		var err error
+
+		if logd {
+			if size, err = alog.DefaultLogd.LogSize(id); err == nil {
+				used, err = alog.DefaultLogd.LogSizeUsed(id)
+			}
+		} else {
+			size, used, err = alog.LoggerLogSize(id)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: ring buffer is %dKb (%dKb consumed)\n", id, size/1024, used/1024)
+	}
+	return nil
+}
+
+// readAll reads entries from reader until it times out or hits io.EOF.
+//
+// Returns the entries read so far and the error if reading fails otherwise.
+func readAll(reader alog.Reader, timeout time.Duration) ([]*alog.Entry, error) {
+	var entries []*alog.Entry
+	for {
+		if timeout > 0 {
+			reader.SetDeadline(time.Now().Add(timeout))
+		}
+
+		entry, err := reader.ReadNext()
+		if err == alog.ErrReadTimeout || err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// A lenientReader can skip malformed entries instead of failing on them.
+type lenientReader interface {
+	SetLenient(lenient bool)
+	Skipped() uint64
+}
+
+// makeLenient makes reader skip malformed entries if it supports doing so,
+// such that a single corrupt entry does not end reading.
+func makeLenient(reader alog.Reader) {
+	if lr, ok := reader.(lenientReader); ok {
+		lr.SetLenient(true)
+	}
+}
+
+// reportSkipped tells the user about the malformed entries skipped by reader.
+func reportSkipped(reader alog.Reader, what string) {
+	if lr, ok := reader.(lenientReader); ok && lr.Skipped() > 0 {
+		fmt.Fprintf(os.Stderr, "alogcat: skipped %d malformed entries in %s\n", lr.Skipped(), what)
+	}
+}
+
+// mostRecent returns the last n entries, all of them if n is 0.
+func mostRecent(entries []*alog.Entry, n int) []*alog.Entry {
+	if n > 0 && len(entries) > n {
+		return entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// abiExtension returns the LoggerAbiExtension for the quirk given by -quirk,
+// or the one detected for the kernel log identified by id.
+func abiExtension(id alog.LogId) (alog.LoggerAbiExtension, error) {
+	if *quirkName == "" {
+		return alog.DetectAbiExtension(id)
+	}
+
+	q, ok := quirk.ByName(*quirkName)
+	if !ok {
+		return nil, fmt.Errorf("Unknown quirk: %s", *quirkName)
+	}
+
+	return alog.NewQuirkAbiExtension(q), nil
+}
+
+func printQuirks() {
+	for _, q := range quirk.All() {
+		fmt.Printf("%-16s %s\n", q.Name, q.Description)
+	}
+}
+
+// openKernel opens the kernel log identified by id, reading the kernel's
+// ring buffer for LogIdKernel.
+func openKernel(id alog.LogId) (alog.Reader, error) {
+	if id == alog.LogIdKernel {
+		return alog.NewKmsgReader()
+	}
+
+	ext, err := abiExtension(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return alog.NewLoggerReader(id, ext)
+}
+
+// readKernel dumps all kernel logs named by buffers, sorted by time, and
+// unless only dumping, keeps on streaming new entries to entries.
+func readKernel(count int, follow bool, entries chan<- *alog.Entry) error {
+	var readers []alog.Reader
+	var backlog []*alog.Entry
+
+	for _, id := range buffers {
+		reader, err := openKernel(id)
+		if err != nil {
+			return err
+		}
+
+		defer reader.Close()
+		defer reportSkipped(reader, id.String())
+		makeLenient(reader)
+
+		readers = append(readers, reader)
+
+		read, err := readAll(reader, dumpTimeout)
+		if err != nil {
+			return err
+		}
+		backlog = append(backlog, read...)
+	}
+
+	sort.SliceStable(backlog, func(i, j int) bool {
+		a, b := backlog[i].When, backlog[j].When
+		return a.Seconds < b.Seconds || (a.Seconds == b.Seconds && a.Nanoseconds < b.Nanoseconds)
+	})
+
+	for _, entry := range mostRecent(backlog, count) {
+		entries <- entry
+	}
+
+	if !follow {
+		return nil
+	}
+
+	// The first reader failing stops all others, closing their readers to
+	// unblock them, such that entries can be closed once we return.
+	errs := make(chan error, len(readers))
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for _, reader := range readers {
+		wg.Add(1)
+		go func(reader alog.Reader) {
+			defer wg.Done()
+
+			reader.SetDeadline(time.Time{})
+			for {
+				entry, err := reader.ReadNext()
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				select {
+				case entries <- entry:
+				case <-stop:
+					return
+				}
+			}
+		}(reader)
+	}
+
+	err := <-errs
+	close(stop)
+	for _, reader := range readers {
+		reader.Close()
+	}
+
+	wg.Wait()
+	return err
+}
+
+// readLogd reads the logs named by buffers from logd.
+func readLogd(count int, follow bool, entries chan<- *alog.Entry) error {
+	reader, err := alog.NewLogdReader(alog.LogdQuery{LogIds: buffers, Tail: count, Pid: int32(*pid), Dump: !follow})
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+	defer reportSkipped(reader, "logd")
+	reader.SetLenient(true)
+
+	for {
+		entry, err := reader.ReadNext()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		entries <- entry
+	}
+}
+
+// readFile reads entries in binary format from fn.
+func readFile(fn string, count int, entries chan<- *alog.Entry) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+
+	reader := alog.NewBinaryReader(bufio.NewReader(f), nil)
+	defer f.Close()
+	defer reportSkipped(reader, fn)
+	reader.SetLenient(true)
+
+	read, err := readAll(reader, 0)
+	for _, entry := range mostRecent(read, count) {
+		entries <- entry
+	}
+	return err
+}
+
+// readPstore reads the entries of the logs named by buffers recovered from
+// pstore, skipping corrupt records.
+func readPstore(count int, entries chan<- *alog.Entry) error {
+	reader, err := alog.NewPstoreReader()
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+	defer reportSkipped(reader, "pstore")
+	reader.SetLenient(true)
+
+	read, err := readAll(reader, 0)
+	if err != nil {
+		return err
+	}
+
+	var selected []*alog.Entry
+	for _, entry := range read {
+		id, _ := entry.LogId()
+		for _, b := range buffers {
+			if b == id {
+				selected = append(selected, entry)
+				break
+			}
+		}
+	}
+
+	for _, entry := range mostRecent(selected, count) {
+		entries <- entry
+	}
+	return nil
+}
+
+func run() error {
+	flag.Parse()
+
+	if err := checkFlags(); err != nil {
+		return err
+	}
+
+	alog.DefaultLogd.Dir = *logdDir
+
+	if *quirkName == "list" {
+		printQuirks()
+		return nil
+	}
+
+	if len(buffers) == 0 {
+		buffers = buffersFlag{alog.LogIdMain, alog.LogIdSystem}
+	}
+
+	logd, err := useLogd()
+	if err != nil {
+		return err
+	}
+
+	if *clear {
+		return clearLogs(logd)
+	}
+
+	if *sizes {
+		return printSizes(logd)
+	}
+
+	f, modifiers, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	var resolver *alog.ProcessResolver
+	if modifiers != 0 {
+		resolver = alog.NewProcessResolver()
+	}
+
+	specs := flag.Args()
+	if len(specs) == 0 {
+		specs = []string{os.Getenv("ANDROID_LOG_TAGS")}
+	}
+
+	filter, err := alog.NewFilter(specs...)
+	if err != nil {
+		return err
+	}
+
+	var re *regexp.Regexp
+	if *pattern != "" {
+		if re, err = regexp.Compile(*pattern); err != nil {
+			return err
+		}
+	}
+
+	var out sink = &textSink{format: f, modifiers: modifiers, resolver: resolver, w: bufio.NewWriter(os.Stdout)}
+	if *syslogUrl != "" {
+		network, address, ok := strings.Cut(*syslogUrl, "://")
+		if !ok {
+			return fmt.Errorf("Malformed syslog url: %s", *syslogUrl)
+		}
+
+		if out, err = alog.NewSyslogSink(alog.SyslogConfig{Network: network, Address: address}); err != nil {
+			return err
+		}
+	} else if *jsonOut {
+		out = alog.NewNDJSONWriter(os.Stdout)
+	} else if *file != "" {
+		if out, err = alog.NewRotatingFileWriter(*file, alog.RotationConfig{
+			Format:    f,
+			Modifiers: modifiers,
+			Resolver:  resolver,
+			MaxSize:   int64(*rotateKb) * 1024,
+			MaxFiles:  *rotateCount,
+		}); err != nil {
+			return err
+		}
+	}
+
+	defer out.Close()
+
+	count, follow := *tailFollow, !*dump && *tail == 0
+	if *tail > 0 {
+		count = *tail
+	}
+
+	entries := make(chan *alog.Entry)
+	done := make(chan error, 1)
+
+	go func() {
+		defer close(entries)
+
+		switch {
+		case *input != "":
+			done <- readFile(*input, count, entries)
+		case *last:
+			done <- readPstore(count, entries)
+		case logd:
+			done <- readLogd(count, follow, entries)
+		default:
+			done <- readKernel(count, follow, entries)
+		}
+	}()
+
+	for entry := range entries {
+		if !filter.Match(entry) {
+			continue
+		}
+
+		if *pid > 0 && entry.Pid != int32(*pid) {
+			continue
+		}
+
+		if re != nil && !re.MatchString(entry.Message) {
+			continue
+		}
+
+		if err := out.WriteEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return <-done
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "alogcat: %s\n", err)
+		os.Exit(1)
+	}
+}
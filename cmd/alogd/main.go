@@ -0,0 +1,45 @@
+// Command alogd emulates Android's logd on Linux hosts, serving the logdr,
+// logdw and logd sockets in a directory of choice until interrupted:
+//
+//	alogd -dir /tmp/logd &
+//	alogcat -logd /tmp/logd -d
+//
+// Without -dir, the sockets are created in a new temporary directory whose
+// path is printed on startup.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vosst/alog/logdtest"
+)
+
+var (
+	dir  = flag.String("dir", "", "Create the sockets in `dir`, a new temporary directory if empty")
+	size = flag.Int("size", logdtest.DefaultBufferSize, "Capacity of every log in `bytes`")
+)
+
+func main() {
+	flag.Parse()
+
+	server, err := logdtest.NewServer(logdtest.Config{Dir: *dir, BufferSize: *size})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alogd: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(server.Logd.Dir)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+
+	if err := server.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "alogd: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,185 @@
+package alog
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Default values of CoalescingConfig.
+const (
+	DefaultCoalescingMaxGap     = 10 * time.Millisecond
+	DefaultCoalescingFrameGap   = 100 * time.Millisecond
+	DefaultCoalescingFlushDelay = 100 * time.Millisecond
+)
+
+// frameLine matches lines of Java stack traces and native backtraces,
+// including the indentation written by the runtime and debuggerd.
+var frameLine = regexp.MustCompile(`^\s*(at \S+\(.*\)|Caused by: .*|\.\.\. \d+ more|#\d+ pc [0-9a-fA-F]+ .*)$`)
+
+// A CoalescingConfig determines which consecutive entries a CoalescingReader merges.
+type CoalescingConfig struct {
+	MaxGap     time.Duration // Max time between consecutive entries to be merged, DefaultCoalescingMaxGap if 0
+	FrameGap   time.Duration // Max time for entries looking like stack frames, DefaultCoalescingFrameGap if 0
+	FlushDelay time.Duration // Max time to wait for a continuation, DefaultCoalescingFlushDelay if 0
+	MaxLines   int           // Max number of lines of a merged entry, unlimited if 0
+}
+
+// A CoalescingReader implements Reader, wrapping another Reader and merging
+// runs of consecutive entries with identical pid, tid, priority and tag and
+// near-identical timestamps into a single entry with a multi-line message,
+// as written for stack traces and dumpsys output.
+//
+// Entries whose message looks like a frame of a Java stack trace ("\tat ...",
+// "Caused by: ...") or of a native backtrace ("  #00 pc ...") are merged
+// across larger gaps. A run is complete once a non-matching entry arrives,
+// MaxLines is reached, or no entry arrives within FlushDelay.
+type CoalescingReader struct {
+	r        Reader           // The reader we coalesce entries from
+	config   CoalescingConfig // Thresholds for merging entries
+	pending  *Entry           // The run being assembled
+	last     Timestamp        // When the last entry of the run was logged
+	lines    int              // Number of lines of the run
+	deadline time.Time        // The deadline set by SetDeadline
+	armed    bool             // Whether r's deadline is set to flush the run
+	err      error            // Error to be returned once the run is flushed
+}
+
+// NewCoalescingReader returns a new CoalescingReader merging the entries read from r.
+func NewCoalescingReader(r Reader, config CoalescingConfig) *CoalescingReader {
+	if config.MaxGap == 0 {
+		config.MaxGap = DefaultCoalescingMaxGap
+	}
+
+	if config.FrameGap == 0 {
+		config.FrameGap = DefaultCoalescingFrameGap
+	}
+
+	if config.FlushDelay == 0 {
+		config.FlushDelay = DefaultCoalescingFlushDelay
+	}
+
+	return &CoalescingReader{r: r, config: config}
+}
+
+// Close closes the underlying Reader.
+func (self *CoalescingReader) Close() error {
+	return self.r.Close()
+}
+
+// SetDeadline adjusts the deadline for reading for a CoalescingReader.
+func (self *CoalescingReader) SetDeadline(t time.Time) error {
+	self.deadline = t
+	self.armed = false
+	return self.r.SetDeadline(t)
+}
+
+// ReadNext returns the next, possibly merged, entry.
+//
+// Returns the errors of the underlying Reader, after returning the run
+// assembled so far.
+func (self *CoalescingReader) ReadNext() (*Entry, error) {
+	if self.pending == nil && self.err != nil {
+		err := self.err
+		self.err = nil
+		return nil, err
+	}
+
+	for {
+		if err := self.arm(); err != nil {
+			return nil, err
+		}
+
+		next, err := self.r.ReadNext()
+		if err != nil {
+			if self.pending == nil {
+				return nil, err
+			}
+
+			if !(err == ErrReadTimeout && self.armed) {
+				self.err = err
+			}
+			return self.flush(), nil
+		}
+
+		if self.pending == nil {
+			self.start(next)
+			continue
+		}
+
+		if self.continues(next) {
+			self.merge(next)
+			continue
+		}
+
+		entry := self.flush()
+		self.start(next)
+		return entry, nil
+	}
+}
+
+// arm adjusts the deadline of the underlying reader such that a pending run
+// is flushed after FlushDelay, and restores the deadline set by SetDeadline otherwise.
+func (self *CoalescingReader) arm() error {
+	if self.pending == nil {
+		if self.armed {
+			self.armed = false
+			return self.r.SetDeadline(self.deadline)
+		}
+		return nil
+	}
+
+	flush := time.Now().Add(self.config.FlushDelay)
+	if !self.deadline.IsZero() && self.deadline.Before(flush) {
+		if self.armed {
+			self.armed = false
+			return self.r.SetDeadline(self.deadline)
+		}
+		return nil
+	}
+
+	self.armed = true
+	return self.r.SetDeadline(flush)
+}
+
+// continues returns true if next continues the pending run.
+func (self *CoalescingReader) continues(next *Entry) bool {
+	p := self.pending
+	if next.Pid != p.Pid || next.Tid != p.Tid || next.Priority != p.Priority || next.Tag != p.Tag {
+		return false
+	}
+
+	if self.config.MaxLines > 0 && self.lines+strings.Count(next.Message, "\n")+1 > self.config.MaxLines {
+		return false
+	}
+
+	gap := next.When.Time().Sub(self.last.Time())
+	if gap < 0 {
+		return false
+	}
+
+	return gap <= self.config.MaxGap || (gap <= self.config.FrameGap && frameLine.MatchString(next.Message))
+}
+
+func (self *CoalescingReader) start(entry *Entry) {
+	self.pending = entry
+	self.last = entry.When
+	self.lines = strings.Count(entry.Message, "\n") + 1
+}
+
+func (self *CoalescingReader) merge(next *Entry) {
+	p := self.pending
+	p.Message += "\n" + next.Message
+	if p.RawMessage != nil || next.RawMessage != nil {
+		p.RawMessage = append(append(p.RawMessage, '\n'), next.RawMessage...)
+	}
+
+	self.last = next.When
+	self.lines += strings.Count(next.Message, "\n") + 1
+}
+
+func (self *CoalescingReader) flush() *Entry {
+	entry := self.pending
+	self.pending = nil
+	return entry
+}
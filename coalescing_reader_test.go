@@ -0,0 +1,143 @@
+package alog
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A stallingReader implements Reader, handing out entries and returning
+// ErrReadTimeout once they are exhausted, recording all deadlines set.
+type stallingReader struct {
+	sliceReader
+	deadlines []time.Time
+}
+
+func (self *stallingReader) SetDeadline(t time.Time) error {
+	self.deadlines = append(self.deadlines, t)
+	return nil
+}
+
+func (self *stallingReader) ReadNext() (*Entry, error) {
+	if len(self.entries) == 0 {
+		return nil, ErrReadTimeout
+	}
+
+	return self.sliceReader.ReadNext()
+}
+
+func coalescingEntry(offset time.Duration, pid int32, tag Tag, msg string) *Entry {
+	base := time.Date(2015, time.December, 31, 12, 0, 0, 0, time.UTC)
+	return &Entry{When: NewTimestamp(base.Add(offset)), Pid: pid, Tid: pid, Priority: PriorityError, Tag: tag, Message: msg}
+}
+
+func readCoalesced(t *testing.T, config CoalescingConfig, entries ...*Entry) []string {
+	cr := NewCoalescingReader(&sliceReader{entries: entries}, config)
+
+	var msgs []string
+	for entry, err := cr.ReadNext(); err != io.EOF; entry, err = cr.ReadNext() {
+		require.NoError(t, err)
+		msgs = append(msgs, entry.Message)
+	}
+
+	return msgs
+}
+
+func TestCoalescingReaderMergesRunsOfEntries(t *testing.T) {
+	msgs := readCoalesced(t, CoalescingConfig{},
+		coalescingEntry(0, 42, "dumpsys", "first"),
+		coalescingEntry(time.Millisecond, 42, "dumpsys", "second"),
+		coalescingEntry(2*time.Millisecond, 42, "dumpsys", "third"),
+		coalescingEntry(3*time.Millisecond, 43, "dumpsys", "other pid"),
+		coalescingEntry(4*time.Millisecond, 43, "other", "other tag"),
+		coalescingEntry(time.Second, 43, "other", "much later"),
+	)
+
+	assert.Equal(t, []string{"first\nsecond\nthird", "other pid", "other tag", "much later"}, msgs)
+}
+
+func TestCoalescingReaderMergesFramesAcrossLargerGaps(t *testing.T) {
+	msgs := readCoalesced(t, CoalescingConfig{},
+		coalescingEntry(0, 42, "AndroidRuntime", "java.lang.NullPointerException"),
+		coalescingEntry(50*time.Millisecond, 42, "AndroidRuntime", "at com.example.Foo.bar(Foo.java:42)"),
+		coalescingEntry(100*time.Millisecond, 42, "AndroidRuntime", "Caused by: java.lang.IllegalStateException"),
+		coalescingEntry(150*time.Millisecond, 42, "AndroidRuntime", "... 3 more"),
+		coalescingEntry(200*time.Millisecond, 42, "AndroidRuntime", "not a frame"),
+		coalescingEntry(time.Second, 42, "DEBUG", "backtrace:"),
+		coalescingEntry(time.Second+50*time.Millisecond, 42, "DEBUG", "#00 pc 0001a2b4  /system/lib64/libc.so (strlen+20)"),
+	)
+
+	assert.Equal(t, []string{
+		"java.lang.NullPointerException\nat com.example.Foo.bar(Foo.java:42)\nCaused by: java.lang.IllegalStateException\n... 3 more",
+		"not a frame",
+		"backtrace:\n#00 pc 0001a2b4  /system/lib64/libc.so (strlen+20)",
+	}, msgs)
+}
+
+func TestCoalescingReaderMergesIndentedFramesReadFromText(t *testing.T) {
+	text := "12-31 12:00:00.000  42  42 E AndroidRuntime: FATAL EXCEPTION: main\n" +
+		"12-31 12:00:00.050  42  42 E AndroidRuntime: \tat com.example.Foo.bar(Foo.java:42)\n" +
+		"12-31 12:00:00.100  42  42 E AndroidRuntime: \tat android.os.Looper.loop(Looper.java:154)\n" +
+		"12-31 12:00:01.000  43  43 F DEBUG   : backtrace:\n" +
+		"12-31 12:00:01.050  43  43 F DEBUG   :     #00 pc 0001a2b4  /system/lib64/libc.so (strlen+20)\n" +
+		"12-31 12:00:01.100  43  43 F DEBUG   :     #01 pc 000c5e10  /system/lib64/libart.so\n"
+
+	tr := NewTextReader(strings.NewReader(text))
+	tr.now = time.Date(2015, time.December, 31, 0, 0, 0, 0, time.Local)
+	cr := NewCoalescingReader(tr, CoalescingConfig{})
+
+	var msgs []string
+	for entry, err := cr.ReadNext(); err != io.EOF; entry, err = cr.ReadNext() {
+		require.NoError(t, err)
+		msgs = append(msgs, entry.Message)
+	}
+
+	assert.Equal(t, []string{
+		"FATAL EXCEPTION: main\n\tat com.example.Foo.bar(Foo.java:42)\n\tat android.os.Looper.loop(Looper.java:154)",
+		"backtrace:\n    #00 pc 0001a2b4  /system/lib64/libc.so (strlen+20)\n    #01 pc 000c5e10  /system/lib64/libart.so",
+	}, msgs)
+}
+
+func TestCoalescingReaderHonorsMaxLines(t *testing.T) {
+	msgs := readCoalesced(t, CoalescingConfig{MaxLines: 2},
+		coalescingEntry(0, 42, "dumpsys", "1"),
+		coalescingEntry(0, 42, "dumpsys", "2"),
+		coalescingEntry(0, 42, "dumpsys", "3"),
+	)
+
+	assert.Equal(t, []string{"1\n2", "3"}, msgs)
+}
+
+func TestCoalescingReaderFlushesPendingRunOnTimeout(t *testing.T) {
+	sr := &stallingReader{sliceReader: sliceReader{entries: []*Entry{
+		coalescingEntry(0, 42, "dumpsys", "first"),
+		coalescingEntry(0, 42, "dumpsys", "second"),
+	}}}
+	cr := NewCoalescingReader(sr, CoalescingConfig{FlushDelay: time.Millisecond})
+
+	entry, err := cr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond", entry.Message)
+
+	_, err = cr.ReadNext()
+	assert.Equal(t, ErrReadTimeout, err)
+
+	require.Len(t, sr.deadlines, 3)
+	assert.False(t, sr.deadlines[0].IsZero())
+	assert.True(t, sr.deadlines[2].IsZero())
+}
+
+func TestCoalescingReaderReturnsErrorAfterPendingRun(t *testing.T) {
+	cr := NewCoalescingReader(&sliceReader{entries: []*Entry{coalescingEntry(0, 42, "dumpsys", "first")}}, CoalescingConfig{})
+
+	entry, err := cr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, "first", entry.Message)
+
+	_, err = cr.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,326 @@
+package alog
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A CrashKind distinguishes the crashes recognized by CrashAnalyzer.
+type CrashKind int
+
+const (
+	CrashNative CrashKind = iota // A native crash dumped by debuggerd
+	CrashJava                    // An uncaught Java exception reported by AndroidRuntime
+	CrashAnr                     // An application not responding, reported by ActivityManager
+)
+
+// String returns a CrashKind as a string.
+func (self CrashKind) String() string {
+	switch self {
+	case CrashNative:
+		return "native"
+	case CrashJava:
+		return "java"
+	case CrashAnr:
+		return "anr"
+	default:
+		return "unknown"
+	}
+}
+
+// A Frame is a single frame of a backtrace.
+type Frame struct {
+	Index  int    // Position of the frame in a native backtrace
+	PC     uint64 // Program counter relative to Module, native frames only
+	Module string // Shared object containing PC, native frames only
+	Symbol string // Function or method, possibly with offset, e.g., strlen+20
+	Source string // File and line, e.g., Foo.java:42, Java frames only
+}
+
+// A Crash describes a native crash, uncaught Java exception or ANR,
+// reassembled from one or more entries.
+type Crash struct {
+	Kind       CrashKind // Kind of the crash
+	When       Timestamp // When the first entry describing the crash was logged
+	Pid        int32     // ID of the crashed process
+	Tid        int32     // ID of the crashed thread
+	Process    string    // Name of the crashed process
+	Thread     string    // Name of the crashed thread
+	Signal     int       // Number of the fatal signal, native crashes only
+	SignalName string    // Name of the fatal signal, e.g., SIGSEGV, native crashes only
+	SignalCode string    // Signal code, e.g., SEGV_MAPERR, native crashes only
+	FaultAddr  uint64    // Faulting address, native crashes only
+	Message    string    // Abort message, exception or ANR reason
+	Causes     []string  // Causing exceptions, Java crashes only
+	Frames     []Frame   // Backtrace of the crashed thread
+	Lines      []string  // All lines describing the crash
+}
+
+// Patterns matching the lines of crash reports.
+var (
+	nativeStartLine  = regexp.MustCompile(`^\*\*\* \*\*\* \*\*\*`)
+	nativePidLine    = regexp.MustCompile(`^pid: (\d+), tid: (\d+), name: (.*?)\s+>>> (.*?) <<<`)
+	nativeSignalLine = regexp.MustCompile(`^signal (\d+) \((\w+)\), code (-?\d+) \(([^)]*)\)(?:, fault addr (?:0x)?([0-9a-fA-F]+))?`)
+	nativeAbortLine  = regexp.MustCompile(`^Abort message: '(.*)'`)
+	nativeFrameLine  = regexp.MustCompile(`^#(\d+) pc ([0-9a-fA-F]+)\s+(\S+)(.*)$`)
+	javaStartLine    = regexp.MustCompile(`^FATAL EXCEPTION: (.*)`)
+	javaProcessLine  = regexp.MustCompile(`^Process: (.*?), PID: (\d+)`)
+	javaFrameLine    = regexp.MustCompile(`^at (.*)\((.*)\)$`)
+	javaCauseLine    = regexp.MustCompile(`^Caused by: (.*)`)
+	javaMoreLine     = regexp.MustCompile(`^\.\.\. \d+ more$`)
+	anrStartLine     = regexp.MustCompile(`^ANR in (\S+)`)
+	anrPidLine       = regexp.MustCompile(`^PID: (\d+)`)
+	anrReasonLine    = regexp.MustCompile(`^Reason: (.*)`)
+)
+
+// crashQuietPeriod is the time after which a crash is considered complete if
+// its writer has not logged any further lines.
+const crashQuietPeriod = time.Second
+
+// A crashKey identifies the writer of the entries describing a crash.
+type crashKey struct {
+	pid int32
+	tag Tag
+}
+
+// A pendingCrash is a crash whose description is not yet complete.
+type pendingCrash struct {
+	crash    *Crash
+	last     Timestamp // When the last line was logged
+	inFrames bool      // Whether we are within the backtrace
+}
+
+// A CrashAnalyzer reassembles native crash dumps, uncaught Java exceptions
+// and ANRs from entries, as found in the crash buffer or in text read by
+// TextReader, and hands complete crashes to a handler. Entries may carry
+// single lines or complete multi-line reports.
+//
+// A crash is complete once its writer logs a line not belonging to it, no
+// further line for a second, or Flush is called.
+type CrashAnalyzer struct {
+	handler func(crash *Crash)
+	pending map[crashKey]*pendingCrash
+}
+
+// NewCrashAnalyzer returns a new CrashAnalyzer handing complete crashes to handler.
+func NewCrashAnalyzer(handler func(crash *Crash)) *CrashAnalyzer {
+	return &CrashAnalyzer{handler: handler, pending: make(map[crashKey]*pendingCrash)}
+}
+
+// Analyze feeds entry to self, calling the handler for all crashes completed by entry.
+func (self *CrashAnalyzer) Analyze(entry *Entry) {
+	for _, key := range self.pendingKeys() {
+		if entry.When.Time().Sub(self.pending[key].last.Time()) > crashQuietPeriod {
+			self.complete(key)
+		}
+	}
+
+	key := crashKey{pid: entry.Pid, tag: entry.Tag}
+	for _, line := range strings.Split(strings.TrimRight(entry.Message, "\n"), "\n") {
+		self.analyzeLine(key, entry, strings.TrimSpace(line))
+	}
+}
+
+// Flush calls the handler for all crashes whose description is still pending.
+func (self *CrashAnalyzer) Flush() {
+	for _, key := range self.pendingKeys() {
+		self.complete(key)
+	}
+}
+
+// Run feeds all entries read from reader to self until reading fails, and
+// flushes pending crashes.
+//
+// Returns nil if reader reaches io.EOF, the error returned by reader otherwise.
+func (self *CrashAnalyzer) Run(reader Reader) error {
+	defer self.Flush()
+
+	for {
+		entry, err := reader.ReadNext()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		self.Analyze(entry)
+	}
+}
+
+// pendingKeys returns the keys of all pending crashes, ordered by when the
+// crashes started and by pid, such that crashes complete in a fixed order.
+func (self *CrashAnalyzer) pendingKeys() []crashKey {
+	keys := make([]crashKey, 0, len(self.pending))
+	for key := range self.pending {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := self.pending[keys[i]].crash.When, self.pending[keys[j]].crash.When
+		if a != b {
+			return a.Time().Before(b.Time())
+		} else if keys[i].pid != keys[j].pid {
+			return keys[i].pid < keys[j].pid
+		}
+		return keys[i].tag < keys[j].tag
+	})
+
+	return keys
+}
+
+func (self *CrashAnalyzer) complete(key crashKey) {
+	p := self.pending[key]
+	delete(self.pending, key)
+
+	if self.handler != nil {
+		self.handler(p.crash)
+	}
+}
+
+func (self *CrashAnalyzer) start(key crashKey, entry *Entry, kind CrashKind) *pendingCrash {
+	if _, ok := self.pending[key]; ok {
+		self.complete(key)
+	}
+
+	p := &pendingCrash{crash: &Crash{Kind: kind, When: entry.When, Pid: entry.Pid, Tid: entry.Tid}}
+	self.pending[key] = p
+	return p
+}
+
+func (self *CrashAnalyzer) analyzeLine(key crashKey, entry *Entry, line string) {
+	switch {
+	case nativeStartLine.MatchString(line):
+		self.start(key, entry, CrashNative)
+	case javaStartLine.MatchString(line):
+		self.start(key, entry, CrashJava)
+	case anrStartLine.MatchString(line):
+		self.start(key, entry, CrashAnr)
+	}
+
+	p, ok := self.pending[key]
+	if !ok {
+		return
+	}
+
+	var belongs bool
+	switch p.crash.Kind {
+	case CrashNative:
+		belongs = p.analyzeNative(line)
+	case CrashJava:
+		belongs = p.analyzeJava(line)
+	case CrashAnr:
+		belongs = p.analyzeAnr(line)
+	}
+
+	if !belongs {
+		self.complete(key)
+		return
+	}
+
+	p.crash.Lines = append(p.crash.Lines, line)
+	p.last = entry.When
+
+	if p.crash.Kind == CrashAnr && p.crash.Message != "" {
+		self.complete(key)
+	}
+}
+
+// analyzeNative parses line of a native crash dump, returning false if it
+// ends the dump.
+func (self *pendingCrash) analyzeNative(line string) bool {
+	c := self.crash
+
+	if m := nativeFrameLine.FindStringSubmatch(line); m != nil {
+		self.inFrames = true
+		c.Frames = append(c.Frames, parseNativeFrame(m))
+		return true
+	}
+
+	if self.inFrames && line != "" {
+		return false
+	}
+
+	if m := nativePidLine.FindStringSubmatch(line); m != nil {
+		c.Pid, c.Tid = parseInt32(m[1]), parseInt32(m[2])
+		c.Thread, c.Process = m[3], m[4]
+	} else if m := nativeSignalLine.FindStringSubmatch(line); m != nil {
+		c.Signal, _ = strconv.Atoi(m[1])
+		c.SignalName, c.SignalCode = m[2], m[4]
+		c.FaultAddr, _ = strconv.ParseUint(m[5], 16, 64)
+	} else if m := nativeAbortLine.FindStringSubmatch(line); m != nil {
+		c.Message = m[1]
+	}
+
+	return true
+}
+
+// parseNativeFrame parses a frame like "#01 pc 0001a2b4  /system/lib64/libc.so (strlen+20) (BuildId: ...)".
+func parseNativeFrame(m []string) Frame {
+	f := Frame{Module: m[3]}
+	f.Index, _ = strconv.Atoi(m[1])
+	f.PC, _ = strconv.ParseUint(m[2], 16, 64)
+
+	rest := strings.TrimSpace(m[4])
+	if i := strings.Index(rest, " (BuildId: "); i >= 0 {
+		rest = rest[:i]
+	}
+
+	if strings.HasPrefix(rest, "(") && strings.HasSuffix(rest, ")") {
+		f.Symbol = rest[1 : len(rest)-1]
+	}
+
+	return f
+}
+
+// analyzeJava parses line of a Java exception report, returning false if it
+// ends the report.
+func (self *pendingCrash) analyzeJava(line string) bool {
+	c := self.crash
+
+	if m := javaStartLine.FindStringSubmatch(line); m != nil {
+		c.Thread = m[1]
+	} else if m := javaProcessLine.FindStringSubmatch(line); m != nil {
+		c.Process, c.Pid = m[1], parseInt32(m[2])
+	} else if m := javaFrameLine.FindStringSubmatch(line); m != nil {
+		self.inFrames = true
+		if len(c.Causes) == 0 {
+			c.Frames = append(c.Frames, Frame{Symbol: m[1], Source: m[2]})
+		}
+	} else if m := javaCauseLine.FindStringSubmatch(line); m != nil {
+		c.Causes = append(c.Causes, m[1])
+	} else if javaMoreLine.MatchString(line) {
+		// Frames shared with the enclosing exception, elided by the runtime.
+	} else if self.inFrames {
+		return false
+	} else if c.Message == "" {
+		c.Message = line
+	} else {
+		c.Message += "\n" + line
+	}
+
+	return true
+}
+
+// analyzeAnr parses line of an ANR report, which is complete once its reason is known.
+func (self *pendingCrash) analyzeAnr(line string) bool {
+	c := self.crash
+
+	if m := anrStartLine.FindStringSubmatch(line); m != nil {
+		c.Process = m[1]
+	} else if m := anrPidLine.FindStringSubmatch(line); m != nil {
+		c.Pid, c.Tid = parseInt32(m[1]), 0
+	} else if m := anrReasonLine.FindStringSubmatch(line); m != nil {
+		c.Message = m[1]
+	}
+
+	return true
+}
+
+func parseInt32(s string) int32 {
+	n, _ := strconv.Atoi(s)
+	return int32(n)
+}
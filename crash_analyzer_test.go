@@ -0,0 +1,129 @@
+package alog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNativeCrash = `10-19 14:12:53.469  5678  5678 F DEBUG   : *** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+10-19 14:12:53.469  5678  5678 F DEBUG   : Build fingerprint: 'vendor/device:11/RQ1A/1:user/release-keys'
+10-19 14:12:53.469  5678  5678 F DEBUG   : ABI: 'arm64'
+10-19 14:12:53.469  5678  5678 F DEBUG   : pid: 1234, tid: 1240, name: RenderThread  >>> com.example.app <<<
+10-19 14:12:53.469  5678  5678 F DEBUG   : signal 11 (SIGSEGV), code 1 (SEGV_MAPERR), fault addr 0xdead
+10-19 14:12:53.470  5678  5678 F DEBUG   :     x0  0000000000000000  x1  0000007fd4c2a8b0
+10-19 14:12:53.470  5678  5678 F DEBUG   : backtrace:
+10-19 14:12:53.470  5678  5678 F DEBUG   :       #00 pc 000000000001a2b4  /system/lib64/libc.so (strlen+20) (BuildId: 0123abcd)
+10-19 14:12:53.470  5678  5678 F DEBUG   :       #01 pc 0000000000012345  /data/app/lib/arm64/libfoo.so (foo::bar(char const*)+64)
+10-19 14:12:53.470  5678  5678 F DEBUG   :       #02 pc 00000000000ab000  /system/lib64/libart.so
+10-19 14:12:53.480  5678  5678 I DEBUG   : Tombstone written to: /data/tombstones/tombstone_00
+`
+
+const testJavaCrash = `10-19 14:13:00.000  2345  2345 E AndroidRuntime: FATAL EXCEPTION: main
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: Process: com.example.app, PID: 2345
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: java.lang.RuntimeException: Unable to start activity
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: 	at android.app.ActivityThread.performLaunchActivity(ActivityThread.java:3449)
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: 	at android.os.Handler.dispatchMessage(Handler.java:106)
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: Caused by: java.lang.NullPointerException: Attempt to invoke virtual method
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: 	at com.example.app.MainActivity.onCreate(MainActivity.java:42)
+10-19 14:13:00.000  2345  2345 E AndroidRuntime: 	... 11 more
+`
+
+func analyzeText(t *testing.T, text string) []*Crash {
+	var crashes []*Crash
+	require.NoError(t, NewCrashAnalyzer(func(crash *Crash) {
+		crashes = append(crashes, crash)
+	}).Run(NewTextReader(strings.NewReader(text))))
+
+	return crashes
+}
+
+func TestCrashAnalyzerReassemblesNativeCrashes(t *testing.T) {
+	crashes := analyzeText(t, testNativeCrash)
+	require.Len(t, crashes, 1)
+
+	c := crashes[0]
+	assert.Equal(t, CrashNative, c.Kind)
+	assert.Equal(t, int32(1234), c.Pid)
+	assert.Equal(t, int32(1240), c.Tid)
+	assert.Equal(t, "com.example.app", c.Process)
+	assert.Equal(t, "RenderThread", c.Thread)
+	assert.Equal(t, 11, c.Signal)
+	assert.Equal(t, "SIGSEGV", c.SignalName)
+	assert.Equal(t, "SEGV_MAPERR", c.SignalCode)
+	assert.Equal(t, uint64(0xdead), c.FaultAddr)
+	assert.Len(t, c.Lines, 10)
+
+	require.Len(t, c.Frames, 3)
+	assert.Equal(t, Frame{Index: 0, PC: 0x1a2b4, Module: "/system/lib64/libc.so", Symbol: "strlen+20"}, c.Frames[0])
+	assert.Equal(t, "foo::bar(char const*)+64", c.Frames[1].Symbol)
+	assert.Equal(t, Frame{Index: 2, PC: 0xab000, Module: "/system/lib64/libart.so"}, c.Frames[2])
+}
+
+func TestCrashAnalyzerReassemblesJavaCrashes(t *testing.T) {
+	crashes := analyzeText(t, testNativeCrash+testJavaCrash)
+	require.Len(t, crashes, 2)
+
+	c := crashes[1]
+	assert.Equal(t, CrashJava, c.Kind)
+	assert.Equal(t, int32(2345), c.Pid)
+	assert.Equal(t, "com.example.app", c.Process)
+	assert.Equal(t, "main", c.Thread)
+	assert.Equal(t, "java.lang.RuntimeException: Unable to start activity", c.Message)
+	assert.Equal(t, []string{"java.lang.NullPointerException: Attempt to invoke virtual method"}, c.Causes)
+
+	require.Len(t, c.Frames, 2)
+	assert.Equal(t, Frame{Symbol: "android.app.ActivityThread.performLaunchActivity", Source: "ActivityThread.java:3449"}, c.Frames[0])
+}
+
+func TestCrashAnalyzerHandlesMultiLineEntries(t *testing.T) {
+	var crashes []*Crash
+	ca := NewCrashAnalyzer(func(crash *Crash) {
+		crashes = append(crashes, crash)
+	})
+
+	ca.Analyze(&Entry{Pid: 1000, Tid: 1010, Priority: PriorityError, Tag: "ActivityManager",
+		Message: "ANR in com.example.app (com.example.app/.MainActivity)\nPID: 2345\nReason: Input dispatching timed out\nLoad: 1.0 / 1.0 / 1.0"})
+
+	require.Len(t, crashes, 1)
+	assert.Equal(t, CrashAnr, crashes[0].Kind)
+	assert.Equal(t, "com.example.app", crashes[0].Process)
+	assert.Equal(t, int32(2345), crashes[0].Pid)
+	assert.Equal(t, "Input dispatching timed out", crashes[0].Message)
+}
+
+func TestCrashAnalyzerCompletesCrashesAfterQuietPeriod(t *testing.T) {
+	var crashes []*Crash
+	ca := NewCrashAnalyzer(func(crash *Crash) {
+		crashes = append(crashes, crash)
+	})
+
+	now := time.Now()
+	ca.Analyze(&Entry{Pid: 2345, Tag: "AndroidRuntime", When: NewTimestamp(now), Message: "FATAL EXCEPTION: main\nProcess: com.example.app, PID: 2345"})
+	assert.Empty(t, crashes)
+
+	ca.Analyze(&Entry{Pid: 1, Tag: "other", When: NewTimestamp(now.Add(2 * time.Second)), Message: "unrelated"})
+	assert.Len(t, crashes, 1)
+}
+
+func TestCrashAnalyzerCompletesPendingCrashesInOrder(t *testing.T) {
+	var pids []int32
+	ca := NewCrashAnalyzer(func(crash *Crash) {
+		pids = append(pids, crash.Pid)
+	})
+
+	now := time.Now()
+	for _, pid := range []int32{300, 100, 400, 200} {
+		when := now
+		if pid == 400 {
+			when = now.Add(-time.Millisecond)
+		}
+		ca.Analyze(&Entry{Pid: pid, Tag: "AndroidRuntime", When: NewTimestamp(when), Message: "FATAL EXCEPTION: main"})
+	}
+
+	ca.Flush()
+	assert.Equal(t, []int32{400, 100, 200, 300}, pids)
+}
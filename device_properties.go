@@ -0,0 +1,87 @@
+package alog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// buildPropFiles lists the files DeviceProperties falls back to if getprop is not available.
+var buildPropFiles = []string{"/default.prop", "/system/build.prop", "/vendor/build.prop"}
+
+// DeviceProperties returns Android's system properties, e.g., ro.product.model
+// and ro.hardware, as reported by getprop. If getprop is not available, the
+// properties are read from the build.prop files instead, lacking the properties
+// set at runtime.
+//
+// Returns an error if neither getprop nor any of the build.prop files is available.
+func DeviceProperties() (map[string]string, error) {
+	if out, err := exec.Command("getprop").Output(); err == nil {
+		return parseGetprop(bytes.NewReader(out)), nil
+	}
+
+	var lastErr error
+	props := make(map[string]string)
+	found := false
+
+	for _, fn := range buildPropFiles {
+		f, err := os.Open(fn)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for k, v := range parseBuildProp(f) {
+			props[k] = v
+		}
+		f.Close()
+		found = true
+	}
+
+	if !found {
+		return nil, lastErr
+	}
+
+	return props, nil
+}
+
+// parseGetprop parses the output of getprop, consisting of lines "[key]: [value]".
+func parseGetprop(r io.Reader) map[string]string {
+	props := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		i := strings.Index(line, "]: [")
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") || i < 0 {
+			continue
+		}
+
+		props[line[1:i]] = line[i+len("]: [") : len(line)-1]
+	}
+
+	return props
+}
+
+// parseBuildProp parses a build.prop file, consisting of lines "key=value" and comments.
+func parseBuildProp(r io.Reader) map[string]string {
+	props := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if i := strings.Index(line, "="); i > 0 {
+			props[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	return props
+}
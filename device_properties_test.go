@@ -0,0 +1,20 @@
+package alog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGetpropExtractsProperties(t *testing.T) {
+	props := parseGetprop(strings.NewReader("[ro.hardware]: [mt6595]\n[ro.product.model]: [MX4]\n[empty]: []\ngarbage\n"))
+
+	assert.Equal(t, map[string]string{"ro.hardware": "mt6595", "ro.product.model": "MX4", "empty": ""}, props)
+}
+
+func TestParseBuildPropSkipsComments(t *testing.T) {
+	props := parseBuildProp(strings.NewReader("# begin build properties\nro.product.model=MX4\n\nro.build.id = KTU84P\n"))
+
+	assert.Equal(t, map[string]string{"ro.product.model": "MX4", "ro.build.id": "KTU84P"}, props)
+}
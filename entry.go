@@ -1,11 +1,23 @@
 package alog
 
+import "time"
+
 // A Timestamp marks the time when an entry was put to a log.
 type Timestamp struct {
 	Seconds     int32 // Seconds since the epoch
 	Nanoseconds int32 // Nanoseconds since the epoch
 }
 
+// NewTimestamp returns the Timestamp corresponding to t.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Seconds: int32(t.Unix()), Nanoseconds: int32(t.Nanosecond())}
+}
+
+// Time returns self as a time.Time in the local timezone.
+func (self Timestamp) Time() time.Time {
+	return time.Unix(int64(self.Seconds), int64(self.Nanoseconds))
+}
+
 // A Tag describes the origin of an Entry.
 type Tag string
 
@@ -52,11 +64,60 @@ const (
 
 // An Entry models an individual log message.
 type Entry struct {
-	Pid      int32                  // Generating process's ID
-	Tid      int32                  // Generating thread's ID
-	When     Timestamp              // When the entry was logged
-	Priority Priority               // Priority of the message
-	Tag      Tag                    // Tag describing the origin of the message
-	Message  string                 // The actual message of the Entry
-	Ext      map[string]interface{} // Vendor specific extensions to individual entries
+	Pid        int32                  // Generating process's ID
+	Tid        int32                  // Generating thread's ID
+	When       Timestamp              // When the entry was logged
+	Priority   Priority               // Priority of the message
+	Tag        Tag                    // Tag describing the origin of the message
+	Message    string                 // The actual message of the Entry, without leading and trailing whitespace
+	RawMessage []byte                 // The exact message bytes, if requested from the reader
+	Ext        map[string]interface{} // Vendor specific extensions to individual entries
+}
+
+// Well-known keys of the extension map Entry.Ext.
+const (
+	ExtEuid     = "euid" // Effective uid of the writer, uint32, kernel logger ABI v2
+	ExtUid      = "uid"  // Uid of the writer, uint32, logd
+	ExtLogId    = "lid"  // Log the entry was read from, LogId, logd
+	ExtTimeZone = "tz"   // Timezone offset, int32, Meizu MX4 kernel logger
+
+	ExtSeq       = "seq"       // Sequence number, uint64, kernel ring buffer
+	ExtFacility  = "facility"  // Syslog facility, int, kernel ring buffer
+	ExtMonotonic = "monotonic" // Timestamp relative to boot, time.Duration, kernel ring buffer
+	ExtDict      = "dict"      // Key/value pairs of continuation lines, map[string]string, kernel ring buffer
+)
+
+// ExtValue returns the value stored under key in the extension map of entry,
+// and whether a value of type T is present.
+func ExtValue[T any](entry *Entry, key string) (T, bool) {
+	v, ok := entry.Ext[key].(T)
+	return v, ok
+}
+
+// Euid returns the effective uid of the writer as reported by the kernel
+// logger's ABI v2, and whether it is present.
+func (self *Entry) Euid() (uint32, bool) {
+	return ExtValue[uint32](self, ExtEuid)
+}
+
+// Uid returns the uid of the writer as reported by logd or, as a fallback,
+// the effective uid reported by the kernel logger's ABI v2, and whether it is present.
+func (self *Entry) Uid() (uint32, bool) {
+	if uid, ok := ExtValue[uint32](self, ExtUid); ok {
+		return uid, true
+	}
+
+	return self.Euid()
+}
+
+// LogId returns the log the entry was read from as reported by logd, and
+// whether it is present.
+func (self *Entry) LogId() (LogId, bool) {
+	return ExtValue[LogId](self, ExtLogId)
+}
+
+// TimeZone returns the timezone offset reported by vendor-specific kernel
+// loggers, and whether it is present.
+func (self *Entry) TimeZone() (int32, bool) {
+	return ExtValue[int32](self, ExtTimeZone)
 }
@@ -0,0 +1,288 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Keys of the fields of an Entry in its JSON encoding, which extension keys must not collide with.
+const (
+	jsonTime     = "time"
+	jsonPid      = "pid"
+	jsonTid      = "tid"
+	jsonPriority = "priority"
+	jsonTag      = "tag"
+	jsonMessage  = "message"
+	jsonRaw      = "raw"
+)
+
+// ExtTypes maps the well-known keys of Entry.Ext to the types of their
+// values, restoring them when decoding entries from JSON.
+var ExtTypes = map[string]reflect.Type{
+	ExtEuid:      reflect.TypeOf(uint32(0)),
+	ExtUid:       reflect.TypeOf(uint32(0)),
+	ExtLogId:     reflect.TypeOf(LogId(0)),
+	ExtTimeZone:  reflect.TypeOf(int32(0)),
+	ExtSeq:       reflect.TypeOf(uint64(0)),
+	ExtFacility:  reflect.TypeOf(int(0)),
+	ExtMonotonic: reflect.TypeOf(time.Duration(0)),
+	ExtDict:      reflect.TypeOf(map[string]string(nil)),
+}
+
+// A jsonField is a key/value pair of the JSON encoding of an Entry.
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+var priorityNames = []string{
+	PriorityUnknown: "unknown",
+	PriorityDefault: "default",
+	PriorityVerbose: "verbose",
+	PriorityDebug:   "debug",
+	PriorityInfo:    "info",
+	PriorityWarn:    "warn",
+	PriorityError:   "error",
+	PriorityFatal:   "fatal",
+	PrioritySilent:  "silent",
+}
+
+// MarshalJSON encodes self as a string in RFC 3339 format with nanoseconds, in UTC.
+func (self Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(self.Time().UTC().Format(time.RFC3339Nano))
+}
+
+// UnmarshalJSON decodes a string in RFC 3339 format into self.
+//
+// Returns an error if data is not such a string.
+func (self *Timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+
+	*self = NewTimestamp(t)
+	return nil
+}
+
+// MarshalJSON encodes self by its lowercase name, e.g., "info", or as a
+// number if self is out of range.
+func (self Priority) MarshalJSON() ([]byte, error) {
+	if self < 0 || int(self) >= len(priorityNames) {
+		return json.Marshal(int(self))
+	}
+
+	return json.Marshal(priorityNames[self])
+}
+
+// UnmarshalJSON decodes a name, a short code as returned by String or a number into self.
+//
+// Returns an error if data does not denote a Priority.
+func (self *Priority) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*self = Priority(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	for p, name := range priorityNames {
+		if name == s {
+			*self = Priority(p)
+			return nil
+		}
+	}
+
+	p, err := ParsePriority(s)
+	if err != nil {
+		return err
+	}
+
+	*self = p
+	return nil
+}
+
+// MarshalJSON encodes self by its name, e.g., "main", or as a number if self
+// does not name a known log.
+func (self LogId) MarshalJSON() ([]byte, error) {
+	if id, err := ParseLogId(self.String()); (err == nil && id == self) || self == LogIdUnknown {
+		return json.Marshal(self.String())
+	}
+
+	return json.Marshal(int(self))
+}
+
+// UnmarshalJSON decodes a name or a number into self.
+//
+// Returns an error if data does not denote a LogId.
+func (self *LogId) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*self = LogId(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	if s == LogIdUnknown.String() {
+		*self = LogIdUnknown
+		return nil
+	}
+
+	id, err := ParseLogId(s)
+	if err != nil {
+		return err
+	}
+
+	*self = id
+	return nil
+}
+
+// MarshalJSON encodes self as a single JSON object carrying time, pid, tid,
+// priority, tag, message and, if present, the base64-encoded raw message,
+// followed by the extension map flattened into the object, e.g.:
+//
+//	{"time":"2015-11-01T12:13:14.015Z","pid":42,"tid":43,"priority":"info","tag":"Test","message":"first","uid":10042}
+//
+// Returns an error if an extension key collides with one of the fields, or
+// if encoding an extension value fails.
+func (self Entry) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+
+	fields := []jsonField{
+		{jsonTime, self.When},
+		{jsonPid, self.Pid},
+		{jsonTid, self.Tid},
+		{jsonPriority, self.Priority},
+		{jsonTag, self.Tag},
+		{jsonMessage, self.Message},
+	}
+
+	if self.RawMessage != nil {
+		fields = append(fields, jsonField{jsonRaw, self.RawMessage})
+	}
+
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := writeJSONField(buf, f.key, f.value); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(self.Ext))
+	for k := range self.Ext {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch k {
+		case jsonTime, jsonPid, jsonTid, jsonPriority, jsonTag, jsonMessage, jsonRaw:
+			return nil, fmt.Errorf("Extension key %s collides with a field of Entry", k)
+		}
+
+		buf.WriteByte(',')
+		if err := writeJSONField(buf, k, self.Ext[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a JSON object as produced by MarshalJSON into self.
+// Keys other than the fields of Entry end up in Ext, with values of the
+// types given by ExtTypes and of the types chosen by encoding/json otherwise.
+//
+// Returns an error if data is not such an object.
+func (self *Entry) UnmarshalJSON(data []byte) error {
+	return unmarshalEntry(data, self, nil)
+}
+
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}) error {
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+	return nil
+}
+
+// unmarshalEntry decodes data into entry, restoring the types of extension
+// values from schema and ExtTypes, in this order.
+func unmarshalEntry(data []byte, entry *Entry, schema map[string]reflect.Type) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*entry = Entry{}
+
+	targets := map[string]interface{}{
+		jsonTime:     &entry.When,
+		jsonPid:      &entry.Pid,
+		jsonTid:      &entry.Tid,
+		jsonPriority: &entry.Priority,
+		jsonTag:      &entry.Tag,
+		jsonMessage:  &entry.Message,
+		jsonRaw:      &entry.RawMessage,
+	}
+
+	for k, raw := range fields {
+		if target, ok := targets[k]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return fmt.Errorf("Failed to decode %s: %w", k, err)
+			}
+			continue
+		}
+
+		t, ok := schema[k]
+		if !ok {
+			t, ok = ExtTypes[k]
+		}
+
+		var value interface{}
+		if ok {
+			v := reflect.New(t)
+			if err := json.Unmarshal(raw, v.Interface()); err != nil {
+				return fmt.Errorf("Failed to decode %s: %w", k, err)
+			}
+			value = v.Elem().Interface()
+		} else if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("Failed to decode %s: %w", k, err)
+		}
+
+		if entry.Ext == nil {
+			entry.Ext = make(map[string]interface{})
+		}
+		entry.Ext[k] = value
+	}
+
+	return nil
+}
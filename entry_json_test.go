@@ -0,0 +1,92 @@
+package alog
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jsonTestEntry = Entry{
+	Pid:        42,
+	Tid:        43,
+	When:       NewTimestamp(time.Date(2015, time.November, 1, 12, 13, 14, 15000000, time.UTC)),
+	Priority:   PriorityInfo,
+	Tag:        testTag,
+	Message:    "first\nsecond",
+	RawMessage: []byte("first\nsecond\xff"),
+	Ext: map[string]interface{}{
+		ExtUid:       uint32(10042),
+		ExtLogId:     LogIdSystem,
+		ExtTimeZone:  int32(-3600),
+		ExtSeq:       uint64(4711),
+		ExtFacility:  3,
+		ExtMonotonic: 1500 * time.Millisecond,
+		ExtDict:      map[string]string{"SUBSYSTEM": "usb"},
+	},
+}
+
+func TestEntryMarshalsCanonicalJSON(t *testing.T) {
+	entry := jsonTestEntry
+	entry.RawMessage = nil
+	entry.Ext = map[string]interface{}{ExtUid: uint32(10042), ExtLogId: LogIdMain}
+
+	b, err := json.Marshal(entry)
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":"2015-11-01T12:13:14.015Z","pid":42,"tid":43,"priority":"info","tag":"Test","message":"first\nsecond","lid":"main","uid":10042}`, string(b))
+}
+
+func TestEntryRoundTripsThroughJSON(t *testing.T) {
+	b, err := json.Marshal(&jsonTestEntry)
+	require.NoError(t, err)
+
+	var entry Entry
+	require.NoError(t, json.Unmarshal(b, &entry))
+	assert.Equal(t, jsonTestEntry, entry)
+}
+
+func TestEntryRejectsCollidingExtensionKeys(t *testing.T) {
+	entry := jsonTestEntry
+	entry.Ext = map[string]interface{}{"tag": "other"}
+
+	_, err := json.Marshal(entry)
+	assert.Error(t, err)
+}
+
+func TestEntryDecodesUnknownExtensionsWithDefaultTypes(t *testing.T) {
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(`{"priority":"E","vendor":1.5,"lid":5}`), &entry))
+
+	assert.Equal(t, PriorityError, entry.Priority)
+	assert.Equal(t, 1.5, entry.Ext["vendor"])
+	assert.Equal(t, LogId(5), entry.Ext[ExtLogId])
+
+	require.NoError(t, unmarshalEntry([]byte(`{"vendor":7}`), &entry, map[string]reflect.Type{"vendor": reflect.TypeOf(int16(0))}))
+	assert.Equal(t, int16(7), entry.Ext["vendor"])
+}
+
+func TestPriorityAndLogIdRoundTripThroughJSON(t *testing.T) {
+	for p := Priority(-1); p <= PrioritySilent+1; p++ {
+		b, err := json.Marshal(p)
+		require.NoError(t, err)
+
+		var parsed Priority
+		require.NoError(t, json.Unmarshal(b, &parsed))
+		assert.Equal(t, p, parsed)
+	}
+
+	for id := LogIdUnknown; id <= LogIdKernel+1; id++ {
+		b, err := json.Marshal(id)
+		require.NoError(t, err)
+
+		var parsed LogId
+		require.NoError(t, json.Unmarshal(b, &parsed))
+		assert.Equal(t, id, parsed)
+	}
+
+	var p Priority
+	assert.Error(t, json.Unmarshal([]byte(`"loud"`), &p))
+}
@@ -0,0 +1,66 @@
+package alog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedAccessorsReturnExtensionFields(t *testing.T) {
+	entry := &Entry{Ext: map[string]interface{}{
+		ExtEuid:     uint32(1000),
+		ExtLogId:    LogIdSystem,
+		ExtTimeZone: int32(3600),
+	}}
+
+	euid, ok := entry.Euid()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1000), euid)
+
+	uid, ok := entry.Uid()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1000), uid)
+
+	lid, ok := entry.LogId()
+	assert.True(t, ok)
+	assert.Equal(t, LogIdSystem, lid)
+
+	tz, ok := entry.TimeZone()
+	assert.True(t, ok)
+	assert.Equal(t, int32(3600), tz)
+}
+
+func TestUidPrefersLogdUid(t *testing.T) {
+	entry := &Entry{Ext: map[string]interface{}{ExtEuid: uint32(0), ExtUid: uint32(10042)}}
+
+	uid, ok := entry.Uid()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(10042), uid)
+}
+
+func TestTypedAccessorsReportMissingFields(t *testing.T) {
+	entry := &Entry{}
+
+	_, ok := entry.Euid()
+	assert.False(t, ok)
+
+	_, ok = entry.Uid()
+	assert.False(t, ok)
+
+	_, ok = entry.LogId()
+	assert.False(t, ok)
+
+	_, ok = entry.TimeZone()
+	assert.False(t, ok)
+}
+
+func TestExtValueChecksType(t *testing.T) {
+	entry := &Entry{Ext: map[string]interface{}{ExtEuid: int64(1000)}}
+
+	_, ok := ExtValue[uint32](entry, ExtEuid)
+	assert.False(t, ok)
+
+	v, ok := ExtValue[int64](entry, ExtEuid)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1000), v)
+}
@@ -0,0 +1,231 @@
+package alog
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	fakeLoggerCapacity   = 64 * 1024 // Default capacity of every log of a fakeLogger
+	fakeLoggerMaxPayload = 4076      // LOGGER_ENTRY_MAX_PAYLOAD of the kernel logger
+)
+
+// A fakeLoggerConfig determines the ABI and limits emulated by a fakeLogger.
+type fakeLoggerConfig struct {
+	MaxVersion   int    // Highest ABI version accepted by SetVersion, 1 if 0
+	Capacity     int    // Capacity in bytes of every log, fakeLoggerCapacity if 0
+	Euid         uint32 // The euid reported for all entries in ABI version 2
+	VendorHeader []byte // Vendor-specific header bytes following the v1 header in ABI version 2
+	V1Headers    bool   // Whether to frame entries with v1 headers regardless of the ABI version
+}
+
+// A fakeRecord is a single entry stored in a fakeLog.
+type fakeRecord struct {
+	entry Entry
+	size  int
+}
+
+// A fakeLog is the in-memory ring buffer of a single log.
+type fakeLog struct {
+	records []fakeRecord  // All records in the log, oldest first
+	first   uint64        // Sequence number of records[0]
+	used    int           // Bytes used by records
+	written chan struct{} // Closed and replaced whenever a record is written
+}
+
+// A fakeLogger emulates the kernel logger's devices in memory, with one ring
+// buffer per log and one cursor per opened device.
+type fakeLogger struct {
+	config fakeLoggerConfig
+	m      sync.Mutex
+	logs   map[LogId]*fakeLog
+}
+
+func newFakeLogger(config fakeLoggerConfig) *fakeLogger {
+	if config.MaxVersion == 0 {
+		config.MaxVersion = 1
+	}
+
+	if config.Capacity == 0 {
+		config.Capacity = fakeLoggerCapacity
+	}
+
+	return &fakeLogger{config: config, logs: make(map[LogId]*fakeLog)}
+}
+
+func (self *fakeLogger) log(id LogId) *fakeLog {
+	l, ok := self.logs[id]
+	if !ok {
+		l = &fakeLog{written: make(chan struct{})}
+		self.logs[id] = l
+	}
+
+	return l
+}
+
+// Open returns a LoggerDevice for the log identified by id, reading from
+// the oldest entry still available in the log.
+func (self *fakeLogger) Open(id LogId) LoggerDevice {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return &fakeLoggerDevice{logger: self, id: id, log: self.log(id), cursor: self.log(id).first, version: 1}
+}
+
+// Append adds entry to the log identified by id, dropping the oldest entries
+// if the log's capacity is exceeded. Readers falling behind silently skip
+// dropped entries, as with the kernel logger.
+func (self *fakeLogger) Append(id LogId, entry Entry) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	l := self.log(id)
+	size := loggerEntryHeaderSize + 1 + len(entry.Tag) + 1 + len(entry.Message) + 1
+
+	l.records = append(l.records, fakeRecord{entry: entry, size: size})
+	l.used += size
+
+	for l.used > self.config.Capacity && len(l.records) > 1 {
+		l.used -= l.records[0].size
+		l.records = l.records[1:]
+		l.first++
+	}
+
+	close(l.written)
+	l.written = make(chan struct{})
+}
+
+// encode frames entry in ABI version.
+func (self *fakeLogger) encode(entry *Entry, version int) []byte {
+	b := appendLoggerEntry(nil, entry)
+	if version < 2 || self.config.V1Headers {
+		return b
+	}
+
+	hdr := append([]byte{}, b[:loggerEntryHeaderSize]...)
+	hdr = append(hdr, self.config.VendorHeader...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, self.config.Euid)
+	binary.LittleEndian.PutUint16(hdr[2:], uint16(len(hdr)))
+
+	return append(hdr, b[loggerEntryHeaderSize:]...)
+}
+
+// A fakeLoggerDevice implements LoggerDevice for a fakeLogger.
+type fakeLoggerDevice struct {
+	logger   *fakeLogger
+	id       LogId
+	log      *fakeLog
+	cursor   uint64
+	version  int
+	deadline time.Time
+	closed   bool
+}
+
+func (self *fakeLoggerDevice) Close() error {
+	self.logger.m.Lock()
+	defer self.logger.m.Unlock()
+
+	self.closed = true
+	return nil
+}
+
+// Read returns the entry at the cursor of self, waiting for one to be
+// written until the deadline expires.
+func (self *fakeLoggerDevice) Read(b []byte) (int, error) {
+	for {
+		self.logger.m.Lock()
+
+		if self.closed {
+			self.logger.m.Unlock()
+			return 0, syscall.EBADF
+		}
+
+		if self.cursor < self.log.first {
+			self.cursor = self.log.first
+		}
+
+		if i := self.cursor - self.log.first; i < uint64(len(self.log.records)) {
+			raw := self.logger.encode(&self.log.records[i].entry, self.version)
+			self.logger.m.Unlock()
+
+			if len(b) < len(raw) {
+				return 0, syscall.EINVAL
+			}
+
+			self.cursor++
+			return copy(b, raw), nil
+		}
+
+		written, deadline := self.log.written, self.deadline
+		self.logger.m.Unlock()
+
+		if deadline.IsZero() {
+			<-written
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-written:
+			timer.Stop()
+		case <-timer.C:
+			return 0, ErrReadTimeout
+		}
+	}
+}
+
+// Write appends the entry with payload b, truncating the payload to the
+// kernel logger's maximum payload size.
+func (self *fakeLoggerDevice) Write(b []byte) (int, error) {
+	n := len(b)
+	if n > fakeLoggerMaxPayload {
+		b = b[:fakeLoggerMaxPayload]
+	}
+
+	entry := Entry{
+		Pid:  int32(os.Getpid()),
+		Tid:  int32(syscall.Gettid()),
+		When: NewTimestamp(time.Now()),
+	}
+
+	if err := parsePayload(b, &entry, nil, false); err != nil {
+		return 0, syscall.EINVAL
+	}
+
+	self.logger.Append(self.id, entry)
+	return n, nil
+}
+
+func (self *fakeLoggerDevice) SetDeadline(t time.Time) error {
+	self.logger.m.Lock()
+	defer self.logger.m.Unlock()
+
+	self.deadline = t
+	return nil
+}
+
+func (self *fakeLoggerDevice) Version() (int, error) {
+	if self.logger.config.MaxVersion < 2 {
+		return 0, syscall.ENOTTY
+	}
+
+	self.logger.m.Lock()
+	defer self.logger.m.Unlock()
+
+	return self.version, nil
+}
+
+func (self *fakeLoggerDevice) SetVersion(version int) error {
+	if version < 1 || version > self.logger.config.MaxVersion {
+		return syscall.EINVAL
+	}
+
+	self.logger.m.Lock()
+	defer self.logger.m.Unlock()
+
+	self.version = version
+	return nil
+}
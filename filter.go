@@ -0,0 +1,89 @@
+package alog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParsePriority returns the Priority identified by its short code s, as
+// used in logcat's filterspecs.
+//
+// Returns an error if s is not a known short code.
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToUpper(s) {
+	case "V":
+		return PriorityVerbose, nil
+	case "D":
+		return PriorityDebug, nil
+	case "I":
+		return PriorityInfo, nil
+	case "W":
+		return PriorityWarn, nil
+	case "E":
+		return PriorityError, nil
+	case "F":
+		return PriorityFatal, nil
+	case "S":
+		return PrioritySilent, nil
+	}
+
+	return PriorityUnknown, fmt.Errorf("Unknown priority: %s", s)
+}
+
+// A Filter selects Entries by tag and minimum priority, following the
+// filterspec syntax of logcat: tag[:priority], where tag * names the
+// default for all other tags and a missing priority means V.
+type Filter struct {
+	def  Priority         // Minimum priority for tags without a dedicated rule
+	tags map[Tag]Priority // Minimum priority per tag
+}
+
+// NewFilter returns a Filter honouring all filterspecs in specs. Without
+// specs, the Filter matches all Entries. Every spec can itself contain
+// multiple whitespace-separated filterspecs, as in $ANDROID_LOG_TAGS.
+//
+// Returns an error if any of the specs is malformed.
+func NewFilter(specs ...string) (*Filter, error) {
+	self := &Filter{def: PriorityVerbose, tags: make(map[Tag]Priority)}
+
+	for _, spec := range specs {
+		for _, s := range strings.Fields(spec) {
+			tag, prio := s, PriorityVerbose
+
+			if i := strings.LastIndex(s, ":"); i >= 0 {
+				p, err := ParsePriority(s[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				tag, prio = s[:i], p
+			}
+
+			if tag == "" {
+				return nil, fmt.Errorf("Invalid filterspec: %s", s)
+			}
+
+			if tag == "*" {
+				self.def = prio
+			} else {
+				self.tags[Tag(tag)] = prio
+			}
+		}
+	}
+
+	return self, nil
+}
+
+// MinPriority returns the minimum priority Entries with tag must have to
+// match self.
+func (self *Filter) MinPriority(tag Tag) Priority {
+	if prio, ok := self.tags[tag]; ok {
+		return prio
+	}
+
+	return self.def
+}
+
+// Match returns true if entry passes self.
+func (self *Filter) Match(entry *Entry) bool {
+	return entry.Priority >= self.MinPriority(entry.Tag) && entry.Priority < PrioritySilent
+}
@@ -0,0 +1,42 @@
+package alog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterWithoutSpecsMatchesAll(t *testing.T) {
+	f, err := NewFilter()
+	require.NoError(t, err)
+
+	assert.True(t, f.Match(&Entry{Priority: PriorityVerbose, Tag: testTag}))
+	assert.True(t, f.Match(&Entry{Priority: PriorityFatal, Tag: testTag}))
+}
+
+func TestFilterHonoursTagRulesAndDefault(t *testing.T) {
+	f, err := NewFilter("ActivityManager:I", "Test", "*:S")
+	require.NoError(t, err)
+
+	assert.True(t, f.Match(&Entry{Priority: PriorityInfo, Tag: "ActivityManager"}))
+	assert.False(t, f.Match(&Entry{Priority: PriorityDebug, Tag: "ActivityManager"}))
+	assert.True(t, f.Match(&Entry{Priority: PriorityVerbose, Tag: testTag}))
+	assert.False(t, f.Match(&Entry{Priority: PriorityFatal, Tag: "Other"}))
+}
+
+func TestFilterSplitsWhitespaceSeparatedSpecs(t *testing.T) {
+	f, err := NewFilter("Test:w *:e")
+	require.NoError(t, err)
+
+	assert.Equal(t, PriorityWarn, f.MinPriority(testTag))
+	assert.Equal(t, PriorityError, f.MinPriority("Other"))
+}
+
+func TestFilterRejectsMalformedSpecs(t *testing.T) {
+	_, err := NewFilter("Test:X")
+	assert.Error(t, err)
+
+	_, err = NewFilter(":I")
+	assert.Error(t, err)
+}
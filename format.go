@@ -0,0 +1,191 @@
+package alog
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Format describes how an Entry is rendered, mirroring the formats
+// supported by logcat's -v option.
+type Format int
+
+const (
+	FormatBrief      Format = iota // P/tag(pid): message
+	FormatProcess                  // P(pid) message  (tag)
+	FormatTag                      // P/tag: message
+	FormatThread                   // P(pid:tid) message
+	FormatRaw                      // message
+	FormatTime                     // date time P/tag(pid): message
+	FormatThreadtime               // date time pid tid P tag: message
+	FormatLong                     // [ date time pid:tid P/tag ] followed by the message and an empty line
+	FormatBinary                   // The binary logger_entry wire format, as produced by logcat -B
+)
+
+var formatNames = []string{
+	FormatBrief:      "brief",
+	FormatProcess:    "process",
+	FormatTag:        "tag",
+	FormatThread:     "thread",
+	FormatRaw:        "raw",
+	FormatTime:       "time",
+	FormatThreadtime: "threadtime",
+	FormatLong:       "long",
+	FormatBinary:     "binary",
+}
+
+// String returns the name of a Format as accepted by logcat -v.
+func (self Format) String() string {
+	if self < 0 || int(self) >= len(formatNames) {
+		return "threadtime"
+	}
+
+	return formatNames[self]
+}
+
+// ParseFormat returns the Format named s.
+//
+// Returns an error if s does not name a known Format.
+func ParseFormat(s string) (Format, error) {
+	for f, name := range formatNames {
+		if name == s {
+			return Format(f), nil
+		}
+	}
+
+	return FormatThreadtime, fmt.Errorf("Unknown log format: %s", s)
+}
+
+// A Modifier adjusts how a Format renders the writer of an entry, mirroring
+// logcat's format modifiers. Modifiers combine as bit flags.
+type Modifier int
+
+const (
+	ModifierUid     Modifier = 1 << iota // Precede the pid by the package or name of the writer's uid
+	ModifierProcess                      // Follow the pid by the name of the writing process
+)
+
+var modifierNames = map[string]Modifier{
+	"uid":     ModifierUid,
+	"process": ModifierProcess,
+}
+
+// ParseModifier returns the Modifier named s.
+//
+// Returns an error if s does not name a known Modifier.
+func ParseModifier(s string) (Modifier, error) {
+	if m, ok := modifierNames[s]; ok {
+		return m, nil
+	}
+
+	return 0, fmt.Errorf("Unknown format modifier: %s", s)
+}
+
+// Render appends entry to buf in Format self. Text formats render every line
+// of a multi-line message with its own prefix.
+func (self Format) Render(buf *bytes.Buffer, entry *Entry) {
+	self.RenderWith(buf, entry, 0, nil)
+}
+
+// RenderWith appends entry to buf in Format self, adjusted by modifiers.
+// Process and package names are looked up with resolver, which might be nil,
+// falling back to the numeric uid and omitting the process name if unknown.
+// Formats lacking the pid ignore modifiers.
+func (self Format) RenderWith(buf *bytes.Buffer, entry *Entry, modifiers Modifier, resolver *ProcessResolver) {
+	if self == FormatBinary {
+		buf.Write(appendLoggerEntry(nil, entry))
+		return
+	}
+
+	t := entry.When.Time()
+	when := fmt.Sprintf("%s.%03d", t.Format("01-02 15:04:05"), t.Nanosecond()/int(time.Millisecond))
+	pid := renderPid(entry, modifiers, resolver)
+
+	var prefix, suffix string
+
+	switch self {
+	case FormatBrief:
+		prefix = fmt.Sprintf("%s/%-8s(%s): ", entry.Priority, entry.Tag, pid)
+	case FormatProcess:
+		prefix = fmt.Sprintf("%s(%s) ", entry.Priority, pid)
+		suffix = fmt.Sprintf("  (%s)", entry.Tag)
+	case FormatTag:
+		prefix = fmt.Sprintf("%s/%-8s: ", entry.Priority, entry.Tag)
+	case FormatThread:
+		prefix = fmt.Sprintf("%s(%s:%5d) ", entry.Priority, pid, entry.Tid)
+	case FormatRaw:
+	case FormatTime:
+		prefix = fmt.Sprintf("%s %s/%-8s(%s): ", when, entry.Priority, entry.Tag, pid)
+	case FormatLong:
+		fmt.Fprintf(buf, "[ %s %s:%5d %s/%-8s ]\n", when, pid, entry.Tid, entry.Priority, entry.Tag)
+		buf.WriteString(strings.TrimRight(entry.Message, "\n"))
+		buf.WriteString("\n\n")
+		return
+	default:
+		prefix = fmt.Sprintf("%s %s %5d %s %-8s: ", when, pid, entry.Tid, entry.Priority, entry.Tag)
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(entry.Message, "\n"), "\n") {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteString(suffix)
+		buf.WriteByte('\n')
+	}
+}
+
+// renderPid renders the pid of entry, adjusted by modifiers.
+func renderPid(entry *Entry, modifiers Modifier, resolver *ProcessResolver) string {
+	pid := fmt.Sprintf("%5d", entry.Pid)
+
+	if modifiers&ModifierProcess != 0 && resolver != nil {
+		if name, ok := resolver.ProcessName(entry.Pid); ok {
+			pid += "/" + name
+		}
+	}
+
+	if modifiers&ModifierUid != 0 {
+		user := "-"
+		if uid, ok := entry.Uid(); ok {
+			user = strconv.FormatUint(uint64(uid), 10)
+			if resolver != nil {
+				if name, ok := resolver.PackageName(uid); ok {
+					user = name
+				}
+			}
+		}
+		pid = fmt.Sprintf("%5s %s", user, pid)
+	}
+
+	return pid
+}
+
+// RenderExt appends the extension fields of entry declared by schema to buf,
+// as space-separated key=value pairs sorted by key. Fields missing from
+// entry or carrying a value of an unexpected type are skipped.
+func RenderExt(buf *bytes.Buffer, entry *Entry, schema map[string]reflect.Type) {
+	keys := make([]string, 0, len(schema))
+	for k := range schema {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	first := true
+	for _, k := range keys {
+		v, ok := entry.Ext[k]
+		if !ok || reflect.TypeOf(v) != schema[k] {
+			continue
+		}
+
+		if !first {
+			buf.WriteByte(' ')
+		}
+
+		fmt.Fprintf(buf, "%s=%v", k, v)
+		first = false
+	}
+}
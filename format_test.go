@@ -0,0 +1,91 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vosst/alog/quirk"
+)
+
+var testEntry = Entry{
+	Pid:      42,
+	Tid:      43,
+	When:     NewTimestamp(time.Date(2015, time.November, 1, 12, 13, 14, 15000000, time.Local)),
+	Priority: PriorityInfo,
+	Tag:      testTag,
+	Message:  "first\nsecond",
+}
+
+func render(format Format, entry *Entry) string {
+	buf := &bytes.Buffer{}
+	format.Render(buf, entry)
+	return buf.String()
+}
+
+func TestFormatRendersLikeLogcat(t *testing.T) {
+	assert.Equal(t, "I/Test    (   42): first\nI/Test    (   42): second\n", render(FormatBrief, &testEntry))
+	assert.Equal(t, "I(   42) first  (Test)\nI(   42) second  (Test)\n", render(FormatProcess, &testEntry))
+	assert.Equal(t, "I/Test    : first\nI/Test    : second\n", render(FormatTag, &testEntry))
+	assert.Equal(t, "I(   42:   43) first\nI(   42:   43) second\n", render(FormatThread, &testEntry))
+	assert.Equal(t, "first\nsecond\n", render(FormatRaw, &testEntry))
+	assert.Equal(t, "11-01 12:13:14.015 I/Test    (   42): first\n11-01 12:13:14.015 I/Test    (   42): second\n", render(FormatTime, &testEntry))
+	assert.Equal(t, "11-01 12:13:14.015    42    43 I Test    : first\n11-01 12:13:14.015    42    43 I Test    : second\n", render(FormatThreadtime, &testEntry))
+	assert.Equal(t, "[ 11-01 12:13:14.015    42:   43 I/Test     ]\nfirst\nsecond\n\n", render(FormatLong, &testEntry))
+}
+
+func TestFormatBinaryFramesEntry(t *testing.T) {
+	b := []byte(render(FormatBinary, &testEntry))
+
+	require.Len(t, b, 20+1+len(testEntry.Tag)+1+len(testEntry.Message)+1)
+	assert.EqualValues(t, len(b)-20, binary.LittleEndian.Uint16(b))
+	assert.EqualValues(t, testEntry.Pid, binary.LittleEndian.Uint32(b[4:]))
+	assert.EqualValues(t, testEntry.Tid, binary.LittleEndian.Uint32(b[8:]))
+	assert.EqualValues(t, testEntry.Priority, b[20])
+	assert.Equal(t, "Test\x00first\nsecond\x00", string(b[21:]))
+}
+
+func TestParseFormatRoundTrips(t *testing.T) {
+	for f := FormatBrief; f <= FormatBinary; f++ {
+		parsed, err := ParseFormat(f.String())
+		assert.NoError(t, err)
+		assert.Equal(t, f, parsed)
+	}
+
+	_, err := ParseFormat("fancy")
+	assert.Error(t, err)
+}
+
+func TestRenderExtFollowsSchema(t *testing.T) {
+	entry := &Entry{Ext: map[string]interface{}{
+		ExtEuid:     uint32(1000),
+		ExtTimeZone: int32(3600),
+		"unknown":   "skipped",
+	}}
+
+	schema := ChainedLoggerAbiExtension{[]LoggerAbiExtension{quirk.MeizuMx4LoggerAbiExtension{}, LoggerAbiV2Extension{}}}.Schema()
+
+	buf := &bytes.Buffer{}
+	RenderExt(buf, entry, schema)
+	assert.Equal(t, "euid=1000 tz=3600", buf.String())
+
+	buf.Reset()
+	RenderExt(buf, &Entry{}, schema)
+	assert.Equal(t, "", buf.String())
+}
+
+func TestParseModifier(t *testing.T) {
+	m, err := ParseModifier("uid")
+	assert.NoError(t, err)
+	assert.Equal(t, ModifierUid, m)
+
+	m, err = ParseModifier("process")
+	assert.NoError(t, err)
+	assert.Equal(t, ModifierProcess, m)
+
+	_, err = ParseModifier("color")
+	assert.Error(t, err)
+}
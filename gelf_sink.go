@@ -0,0 +1,224 @@
+package alog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A GelfCompression selects how a GelfSink compresses messages.
+type GelfCompression int
+
+const (
+	GelfCompressNone GelfCompression = iota // Send messages uncompressed
+	GelfCompressGzip                        // Compress messages with gzip
+	GelfCompressZlib                        // Compress messages with zlib
+)
+
+// DefaultGelfChunkSize is the max size of a datagram sent by a GelfSink,
+// suitable for paths across the internet.
+const DefaultGelfChunkSize = 1420
+
+const (
+	gelfChunkHeaderSize = 12  // Size of the header of a chunk: magic, message id, sequence number and count
+	gelfMaxChunks       = 128 // Max number of chunks of a single message
+)
+
+// gelfFieldName matches the names of additional fields accepted by Graylog.
+var gelfFieldName = regexp.MustCompile(`^[\w.\-]+$`)
+
+// A GelfConfig bundles the options of a GelfSink.
+type GelfConfig struct {
+	Address     string          // host:port of the Graylog GELF UDP input
+	Hostname    string          // Value of the host field, os.Hostname if empty
+	Compression GelfCompression // How to compress messages
+	ChunkSize   int             // Max size of a datagram, DefaultGelfChunkSize if 0
+}
+
+// A GelfSink forwards Entries as GELF 1.1 messages over UDP, splitting
+// messages exceeding ChunkSize into chunks. The first line of a message
+// becomes the short_message, the complete message the full_message if it
+// spans multiple lines. Tag, pid, tid and extensions are sent as additional
+// fields _tag, _pid, _tid and _<key>. A GelfSink is safe for concurrent use.
+type GelfSink struct {
+	config GelfConfig
+	m      sync.Mutex // Serializes writes to conn
+	conn   net.Conn   // Connection to the Graylog input
+}
+
+// NewGelfSink returns a GelfSink forwarding entries as configured by config.
+//
+// Returns an error if resolving config.Address fails.
+func NewGelfSink(config GelfConfig) (*GelfSink, error) {
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+
+	if config.ChunkSize == 0 {
+		config.ChunkSize = DefaultGelfChunkSize
+	}
+
+	if config.ChunkSize <= gelfChunkHeaderSize {
+		return nil, fmt.Errorf("GELF chunk size %d does not exceed the chunk header", config.ChunkSize)
+	}
+
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GelfSink{config: config, conn: conn}, nil
+}
+
+// Close closes the connection to the Graylog input.
+func (self *GelfSink) Close() error {
+	return self.conn.Close()
+}
+
+// WriteEntry encodes entry, compresses it if configured and sends it,
+// chunked if necessary.
+//
+// Returns an error if the message requires more than 128 chunks.
+// Returns an error if encoding or sending fails.
+func (self *GelfSink) WriteEntry(entry *Entry) error {
+	msg, err := self.encode(entry)
+	if err != nil {
+		return err
+	}
+
+	if msg, err = self.compress(msg); err != nil {
+		return err
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if len(msg) <= self.config.ChunkSize {
+		_, err := self.conn.Write(msg)
+		return err
+	}
+
+	data := self.config.ChunkSize - gelfChunkHeaderSize
+	count := (len(msg) + data - 1) / data
+	if count > gelfMaxChunks {
+		return fmt.Errorf("GELF message of %d bytes exceeds %d chunks", len(msg), gelfMaxChunks)
+	}
+
+	chunk := make([]byte, gelfChunkHeaderSize, self.config.ChunkSize)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return err
+	}
+	chunk[11] = byte(count)
+
+	for i := 0; i < count; i++ {
+		end := (i + 1) * data
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk[10] = byte(i)
+		if _, err := self.conn.Write(append(chunk[:gelfChunkHeaderSize], msg[i*data:end]...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encode encodes entry as a GELF 1.1 JSON object.
+func (self *GelfSink) encode(entry *Entry) ([]byte, error) {
+	t := entry.When.Time()
+	short, _, multiline := strings.Cut(entry.Message, "\n")
+
+	fields := map[string]interface{}{
+		"version":       "1.1",
+		"host":          self.config.Hostname,
+		"short_message": short,
+		"timestamp":     float64(t.UnixMilli()) / 1000,
+		"level":         syslogSeverity(entry.Priority),
+		"_tag":          string(entry.Tag),
+		"_pid":          entry.Pid,
+		"_tid":          entry.Tid,
+	}
+
+	if short == "" {
+		fields["short_message"] = "-"
+	}
+
+	if multiline {
+		fields["full_message"] = entry.Message
+	}
+
+	keys := make([]string, 0, len(entry.Ext))
+	for k := range entry.Ext {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		name := "_" + k
+		if _, ok := fields[name]; ok || k == "id" || !gelfFieldName.MatchString(k) {
+			continue
+		}
+
+		fields[name] = gelfValue(entry.Ext[k])
+	}
+
+	return json.Marshal(fields)
+}
+
+// compress compresses msg as configured.
+func (self *GelfSink) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch self.config.Compression {
+	case GelfCompressGzip:
+		w = gzip.NewWriter(&buf)
+	case GelfCompressZlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		return msg, nil
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gelfValue maps an extension value to a GELF field value, which is either
+// a number or a string. Values implementing fmt.Stringer, e.g., LogId or
+// time.Duration, are sent as strings.
+func gelfValue(v interface{}) interface{} {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v
+	}
+
+	return fmt.Sprint(v)
+}
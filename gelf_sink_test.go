@@ -0,0 +1,107 @@
+package alog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withGelfListener hands a GelfSink configured by config and sending to a
+// local UDP listener to f.
+func withGelfListener(t *testing.T, config GelfConfig, f func(conn net.PacketConn, sink *GelfSink)) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	config.Address = conn.LocalAddr().String()
+	sink, err := NewGelfSink(config)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	f(conn, sink)
+}
+
+func readDatagram(t *testing.T, conn net.PacketConn) []byte {
+	b := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(b)
+	require.NoError(t, err)
+	return b[:n]
+}
+
+func TestGelfSinkMapsEntryFields(t *testing.T) {
+	withGelfListener(t, GelfConfig{Hostname: "device"}, func(conn net.PacketConn, sink *GelfSink) {
+		entry := syslogTestEntry
+		entry.Ext = map[string]interface{}{ExtUid: uint32(10042), ExtLogId: LogIdSystem, "id": 1, "bad key": 2}
+		require.NoError(t, sink.WriteEntry(&entry))
+
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(readDatagram(t, conn), &msg))
+
+		assert.Equal(t, map[string]interface{}{
+			"version":       "1.1",
+			"host":          "device",
+			"short_message": "first",
+			"full_message":  "first\nsecond",
+			"timestamp":     1446379994.015,
+			"level":         float64(3),
+			"_tag":          "Test Tag",
+			"_pid":          float64(42),
+			"_tid":          float64(43),
+			"_uid":          float64(10042),
+			"_lid":          "system",
+		}, msg)
+	})
+}
+
+func TestGelfSinkChunksCompressedMessages(t *testing.T) {
+	withGelfListener(t, GelfConfig{Hostname: "device", Compression: GelfCompressGzip, ChunkSize: 64}, func(conn net.PacketConn, sink *GelfSink) {
+		entry := syslogTestEntry
+		entry.Message = strings.Repeat("0123456789abcdef", 64)
+		require.NoError(t, sink.WriteEntry(&entry))
+
+		var chunks [][]byte
+		for count := 1; len(chunks) < count; {
+			chunk := readDatagram(t, conn)
+			require.True(t, len(chunk) > gelfChunkHeaderSize)
+			assert.LessOrEqual(t, len(chunk), 64)
+			assert.Equal(t, []byte{0x1e, 0x0f}, chunk[:2])
+			assert.EqualValues(t, len(chunks), chunk[10])
+			count = int(chunk[11])
+			chunks = append(chunks, chunk)
+		}
+
+		require.True(t, len(chunks) > 1)
+
+		var payload []byte
+		for _, chunk := range chunks {
+			assert.Equal(t, chunks[0][2:10], chunk[2:10])
+			payload = append(payload, chunk[gelfChunkHeaderSize:]...)
+		}
+
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+
+		var msg map[string]interface{}
+		require.NoError(t, json.Unmarshal(b, &msg))
+		assert.Equal(t, entry.Message, msg["short_message"])
+	})
+}
+
+func TestGelfSinkRejectsOversizedMessages(t *testing.T) {
+	withGelfListener(t, GelfConfig{ChunkSize: gelfChunkHeaderSize + 1}, func(conn net.PacketConn, sink *GelfSink) {
+		entry := syslogTestEntry
+		entry.Message = strings.Repeat("x", 1024)
+		assert.Error(t, sink.WriteEntry(&entry))
+	})
+}
@@ -0,0 +1,391 @@
+package alog
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultSubscriptionQueue is the number of entries queued per Subscription
+// if not configured otherwise.
+const DefaultSubscriptionQueue = 256
+
+var (
+	// ErrHubClosed is returned when subscribing to a closed Hub.
+	ErrHubClosed = errors.New("Hub is closed")
+	// ErrSubscriptionOverflow ends a Subscription with policy OverflowEvict
+	// that fell behind.
+	ErrSubscriptionOverflow = errors.New("Subscription fell behind and was evicted")
+)
+
+// An OverflowPolicy determines what happens to entries arriving for a
+// Subscription whose queue is full.
+type OverflowPolicy int
+
+const (
+	OverflowDropOldest OverflowPolicy = iota // Drop the oldest queued entry to make room
+	OverflowDropNewest                       // Drop the arriving entry
+	OverflowBlock                            // Wait for room, stalling delivery to all subscribers of the log
+	OverflowEvict                            // End the Subscription with ErrSubscriptionOverflow
+)
+
+// A SubscriptionConfig bundles the options of a Subscription.
+type SubscriptionConfig struct {
+	LogIds   []LogId        // Logs to receive entries from, LogIdMain if empty
+	Filter   *Filter        // Selects the entries to receive, all if nil
+	Queue    int            // Number of entries queued, DefaultSubscriptionQueue if 0
+	Overflow OverflowPolicy // What to do once the queue is full
+}
+
+// A hubFeed is a single reader of a Hub together with its subscribers.
+type hubFeed struct {
+	reader      Reader
+	subscribers []*Subscription // Replaced rather than modified, such that pump can iterate without holding the lock
+	closing     bool            // Whether reader was closed on purpose
+}
+
+// A Hub owns a single Reader per log and broadcasts the entries read to any
+// number of subscribers, instead of every consumer opening its own reader.
+// Readers are opened on the first subscription to a log and closed once the
+// last subscriber of the log is gone. Entries are shared between subscribers
+// and must not be modified; they carry their log under key 'lid' in their
+// Ext field. A Hub is safe for concurrent use.
+type Hub struct {
+	open func(id LogId) (Reader, error) // Opens the reader of a log
+
+	m      sync.Mutex
+	feeds  map[LogId]*hubFeed // Feeds by log
+	closed bool               // Whether Close was called
+}
+
+// NewHub returns a Hub reading from the kernel logger, detecting the ABI of
+// every log, and from the kernel's ring buffer for LogIdKernel.
+func NewHub() *Hub {
+	return NewHubWithOpener(func(id LogId) (Reader, error) {
+		if id == LogIdKernel {
+			return NewKmsgReader()
+		}
+
+		ext, err := DetectAbiExtension(id)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLoggerReader(id, ext)
+	})
+}
+
+// NewHubWithOpener returns a Hub opening the reader of a log with open, e.g.,
+// for reading from logd. The Hub clears the deadline of every reader opened,
+// such that ErrReadTimeout ends the subscriptions of its log like any other
+// error but *ParseError.
+func NewHubWithOpener(open func(id LogId) (Reader, error)) *Hub {
+	return &Hub{open: open, feeds: make(map[LogId]*hubFeed)}
+}
+
+// Subscribe returns a new Subscription receiving the entries read after
+// subscribing, as configured by config.
+//
+// Returns ErrHubClosed if self was closed.
+// Returns an error if opening the reader of any of the logs fails.
+func (self *Hub) Subscribe(config SubscriptionConfig) (*Subscription, error) {
+	if len(config.LogIds) == 0 {
+		config.LogIds = []LogId{LogIdMain}
+	}
+
+	if config.Queue == 0 {
+		config.Queue = DefaultSubscriptionQueue
+	}
+
+	sub := &Subscription{
+		hub:     self,
+		config:  config,
+		entries: make(chan *Entry, config.Queue),
+		done:    make(chan struct{}),
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if self.closed {
+		return nil, ErrHubClosed
+	}
+
+	for _, id := range config.LogIds {
+		feed, ok := self.feeds[id]
+		if !ok {
+			reader, err := self.open(id)
+			if err != nil {
+				self.remove(sub)
+				return nil, err
+			}
+
+			feed = &hubFeed{reader: reader}
+			self.feeds[id] = feed
+			go self.pump(id, feed)
+		}
+
+		feed.subscribers = append(feed.subscribers[:len(feed.subscribers):len(feed.subscribers)], sub)
+	}
+
+	return sub, nil
+}
+
+// Subscribers returns the number of subscribers of the log identified by id.
+func (self *Hub) Subscribers(id LogId) int {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if feed, ok := self.feeds[id]; ok {
+		return len(feed.subscribers)
+	}
+
+	return 0
+}
+
+// Close ends all subscriptions and closes all readers.
+func (self *Hub) Close() error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.closed = true
+
+	var errs []error
+	for id, feed := range self.feeds {
+		for _, sub := range feed.subscribers {
+			sub.end(io.EOF)
+		}
+
+		feed.closing = true
+		errs = append(errs, feed.reader.Close())
+		delete(self.feeds, id)
+	}
+
+	return errors.Join(errs...)
+}
+
+// unsubscribe removes sub from all feeds and ends it with err.
+func (self *Hub) unsubscribe(sub *Subscription, err error) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.remove(sub)
+	sub.end(err)
+}
+
+// remove removes sub from all feeds, closing feeds without subscribers.
+// Expects self.m to be held.
+func (self *Hub) remove(sub *Subscription) {
+	for _, id := range sub.config.LogIds {
+		feed, ok := self.feeds[id]
+		if !ok {
+			continue
+		}
+
+		subscribers := make([]*Subscription, 0, len(feed.subscribers))
+		for _, s := range feed.subscribers {
+			if s != sub {
+				subscribers = append(subscribers, s)
+			}
+		}
+		feed.subscribers = subscribers
+
+		if len(subscribers) == 0 {
+			feed.closing = true
+			feed.reader.Close()
+			delete(self.feeds, id)
+		}
+	}
+}
+
+// pump reads entries from the reader of feed and delivers them to all
+// subscribers. If reading fails unexpectedly, the subscribers of the log
+// are ended with the error.
+func (self *Hub) pump(id LogId, feed *hubFeed) {
+	// A deadline set by the opener would make every read time out.
+	err := feed.reader.SetDeadline(time.Time{})
+	for err == nil {
+		var entry *Entry
+		entry, err = feed.reader.ReadNext()
+
+		var pe *ParseError
+		if errors.As(err, &pe) {
+			err = nil
+			continue
+		} else if err != nil {
+			break
+		}
+
+		if _, ok := entry.LogId(); !ok {
+			if entry.Ext == nil {
+				entry.Ext = make(map[string]interface{})
+			}
+			entry.Ext[ExtLogId] = id
+		}
+
+		self.m.Lock()
+		subscribers := feed.subscribers
+		self.m.Unlock()
+
+		for _, sub := range subscribers {
+			sub.deliver(entry)
+		}
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if !feed.closing {
+		for _, sub := range feed.subscribers {
+			self.remove(sub)
+			sub.end(err)
+		}
+	}
+}
+
+// A Subscription implements Reader, receiving entries from a Hub. Closing a
+// Subscription unsubscribes it, closing the readers of logs left without
+// subscribers.
+type Subscription struct {
+	hub     *Hub
+	config  SubscriptionConfig
+	entries chan *Entry   // Queued entries, never closed
+	done    chan struct{} // Closed once the subscription ended
+
+	m        sync.Mutex // Serializes dropping the oldest entries and guards the fields below
+	err      error      // The error that ended the subscription
+	dropped  uint64     // Number of entries dropped due to a full queue
+	deadline time.Time  // The deadline set by SetDeadline
+}
+
+// Close unsubscribes self from its Hub. Entries still queued can be read
+// before ReadNext returns io.EOF.
+func (self *Subscription) Close() error {
+	self.hub.unsubscribe(self, io.EOF)
+	return nil
+}
+
+// SetDeadline adjusts the deadline for reading for a Subscription.
+func (self *Subscription) SetDeadline(t time.Time) error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.deadline = t
+	return nil
+}
+
+// Dropped returns the number of entries dropped due to a full queue.
+func (self *Subscription) Dropped() uint64 {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return self.dropped
+}
+
+// ReadNext returns the next queued entry, waiting for one to arrive if necessary.
+//
+// Returns ErrReadTimeout if the deadline passes before an entry arrives.
+// Returns io.EOF once the subscription was closed and all queued entries were read.
+// Returns ErrSubscriptionOverflow if the subscription was evicted.
+// Returns the error of the underlying reader if reading from a log failed.
+func (self *Subscription) ReadNext() (*Entry, error) {
+	select {
+	case entry := <-self.entries:
+		return entry, nil
+	default:
+	}
+
+	self.m.Lock()
+	deadline := self.deadline
+	self.m.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return nil, ErrReadTimeout
+		}
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case entry := <-self.entries:
+		return entry, nil
+	case <-self.done:
+		select {
+		case entry := <-self.entries:
+			return entry, nil
+		default:
+		}
+
+		self.m.Lock()
+		defer self.m.Unlock()
+		return nil, self.err
+	case <-timeout:
+		return nil, ErrReadTimeout
+	}
+}
+
+// deliver queues entry if it passes the filter, applying the overflow
+// policy if the queue is full.
+func (self *Subscription) deliver(entry *Entry) {
+	if self.config.Filter != nil && !self.config.Filter.Match(entry) {
+		return
+	}
+
+	select {
+	case <-self.done:
+		return
+	case self.entries <- entry:
+		return
+	default:
+	}
+
+	switch self.config.Overflow {
+	case OverflowDropNewest:
+		self.m.Lock()
+		self.dropped++
+		self.m.Unlock()
+	case OverflowBlock:
+		select {
+		case self.entries <- entry:
+		case <-self.done:
+		}
+	case OverflowEvict:
+		self.hub.unsubscribe(self, ErrSubscriptionOverflow)
+	default:
+		self.m.Lock()
+		defer self.m.Unlock()
+
+		for {
+			select {
+			case self.entries <- entry:
+				return
+			default:
+			}
+
+			select {
+			case <-self.entries:
+				self.dropped++
+			default:
+			}
+		}
+	}
+}
+
+// end ends self with err unless it already ended.
+func (self *Subscription) end(err error) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	select {
+	case <-self.done:
+	default:
+		self.err = err
+		close(self.done)
+	}
+}
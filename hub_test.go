@@ -0,0 +1,241 @@
+package alog
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A fakeOpener hands out chanReaders, counting the readers opened per log.
+type fakeOpener struct {
+	m       sync.Mutex
+	readers map[LogId]*chanReader
+	opened  map[LogId]int
+	err     error
+}
+
+func newFakeOpener() *fakeOpener {
+	return &fakeOpener{readers: make(map[LogId]*chanReader), opened: make(map[LogId]int)}
+}
+
+func (self *fakeOpener) open(id LogId) (Reader, error) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	self.opened[id]++
+	self.readers[id] = newChanReader()
+	return self.readers[id], nil
+}
+
+func (self *fakeOpener) reader(id LogId) *chanReader {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return self.readers[id]
+}
+
+func readWithin(t *testing.T, sub *Subscription) *Entry {
+	require.NoError(t, sub.SetDeadline(time.Now().Add(2*time.Second)))
+	entry, err := sub.ReadNext()
+	require.NoError(t, err)
+	return entry
+}
+
+func TestHubSharesOneReaderPerLog(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(fo.open)
+	defer hub.Close()
+
+	first, err := hub.Subscribe(SubscriptionConfig{LogIds: []LogId{LogIdMain, LogIdSystem}})
+	require.NoError(t, err)
+
+	filter, err := NewFilter("Test:E", "*:S")
+	require.NoError(t, err)
+
+	second, err := hub.Subscribe(SubscriptionConfig{Filter: filter})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, fo.opened[LogIdMain])
+	assert.Equal(t, 1, fo.opened[LogIdSystem])
+	assert.Equal(t, 2, hub.Subscribers(LogIdMain))
+	assert.Equal(t, 1, hub.Subscribers(LogIdSystem))
+
+	fo.reader(LogIdMain).entries <- &Entry{Priority: PriorityInfo, Tag: "Test", Message: "info"}
+	fo.reader(LogIdMain).entries <- &Entry{Priority: PriorityError, Tag: "Test", Message: "error"}
+
+	assert.Equal(t, "info", readWithin(t, first).Message)
+	assert.Equal(t, "error", readWithin(t, first).Message)
+
+	fo.reader(LogIdSystem).entries <- &Entry{Priority: PriorityError, Tag: "Test", Message: "system"}
+
+	entry := readWithin(t, first)
+	assert.Equal(t, "system", entry.Message)
+	id, _ := entry.LogId()
+	assert.Equal(t, LogIdSystem, id)
+
+	assert.Equal(t, "error", readWithin(t, second).Message)
+}
+
+func TestHubClosesReadersWithoutSubscribers(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(fo.open)
+	defer hub.Close()
+
+	sub, err := hub.Subscribe(SubscriptionConfig{})
+	require.NoError(t, err)
+
+	reader := fo.reader(LogIdMain)
+	reader.entries <- &Entry{Message: "queued"}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sub.entries) == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for delivery")
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	require.NoError(t, sub.Close())
+	assert.Equal(t, 0, hub.Subscribers(LogIdMain))
+
+	_, ok := <-reader.entries
+	assert.False(t, ok, "Reader was not closed")
+
+	// Queued entries remain readable.
+	assert.Equal(t, "queued", readWithin(t, sub).Message)
+	_, err = sub.ReadNext()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = hub.Subscribe(SubscriptionConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fo.opened[LogIdMain])
+}
+
+func TestSubscriptionOverflowPolicies(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(fo.open)
+	defer hub.Close()
+
+	oldest, err := hub.Subscribe(SubscriptionConfig{Queue: 2, Overflow: OverflowDropOldest})
+	require.NoError(t, err)
+	newest, err := hub.Subscribe(SubscriptionConfig{Queue: 2, Overflow: OverflowDropNewest})
+	require.NoError(t, err)
+	evicted, err := hub.Subscribe(SubscriptionConfig{Queue: 2, Overflow: OverflowEvict})
+	require.NoError(t, err)
+
+	for _, msg := range []string{"1", "2", "3"} {
+		fo.reader(LogIdMain).entries <- &Entry{Message: msg}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for oldest.Dropped() < 1 || newest.Dropped() < 1 || hub.Subscribers(LogIdMain) != 2 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for overflow")
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.Equal(t, "2", readWithin(t, oldest).Message)
+	assert.Equal(t, "3", readWithin(t, oldest).Message)
+	assert.Equal(t, "1", readWithin(t, newest).Message)
+	assert.Equal(t, "2", readWithin(t, newest).Message)
+
+	assert.Equal(t, "1", readWithin(t, evicted).Message)
+	assert.Equal(t, "2", readWithin(t, evicted).Message)
+	_, err = evicted.ReadNext()
+	assert.Equal(t, ErrSubscriptionOverflow, err)
+}
+
+func TestSubscriptionBlocksOnOverflow(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(fo.open)
+	defer hub.Close()
+
+	sub, err := hub.Subscribe(SubscriptionConfig{Queue: 1, Overflow: OverflowBlock})
+	require.NoError(t, err)
+
+	sent := make(chan struct{})
+	go func() {
+		for _, msg := range []string{"1", "2", "3"} {
+			fo.reader(LogIdMain).entries <- &Entry{Message: msg}
+		}
+		close(sent)
+	}()
+
+	for _, msg := range []string{"1", "2", "3"} {
+		assert.Equal(t, msg, readWithin(t, sub).Message)
+	}
+
+	<-sent
+	assert.EqualValues(t, 0, sub.Dropped())
+}
+
+func TestSubscriptionTimesOut(t *testing.T) {
+	hub := NewHubWithOpener(newFakeOpener().open)
+	defer hub.Close()
+
+	sub, err := hub.Subscribe(SubscriptionConfig{})
+	require.NoError(t, err)
+
+	require.NoError(t, sub.SetDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err = sub.ReadNext()
+	assert.Equal(t, ErrReadTimeout, err)
+}
+
+func TestHubEndsSubscriptionsOnReaderFailureAndClose(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(fo.open)
+
+	sub, err := hub.Subscribe(SubscriptionConfig{LogIds: []LogId{LogIdRadio}})
+	require.NoError(t, err)
+
+	// The chanReader returns io.EOF once closed behind the hub's back.
+	fo.reader(LogIdRadio).Close()
+	_, err = sub.ReadNext()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 0, hub.Subscribers(LogIdRadio))
+
+	other, err := hub.Subscribe(SubscriptionConfig{})
+	require.NoError(t, err)
+
+	require.NoError(t, hub.Close())
+	_, err = other.ReadNext()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = hub.Subscribe(SubscriptionConfig{})
+	assert.Equal(t, ErrHubClosed, err)
+}
+
+func TestHubClearsDeadlineOfReaders(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(func(id LogId) (Reader, error) {
+		reader, err := fo.open(id)
+		if err == nil {
+			err = reader.SetDeadline(time.Now().Add(-time.Second))
+		}
+		return reader, err
+	})
+	defer hub.Close()
+
+	sub, err := hub.Subscribe(SubscriptionConfig{})
+	require.NoError(t, err)
+
+	fo.reader(LogIdMain).entries <- &Entry{Message: "after deadline"}
+	assert.Equal(t, "after deadline", readWithin(t, sub).Message)
+}
+
+func TestHubReportsOpenErrors(t *testing.T) {
+	fo := newFakeOpener()
+	hub := NewHubWithOpener(fo.open)
+	defer hub.Close()
+
+	fo.err = errors.New("No such log")
+	_, err := hub.Subscribe(SubscriptionConfig{})
+	assert.Equal(t, fo.err, err)
+	assert.Equal(t, 0, hub.Subscribers(LogIdMain))
+}
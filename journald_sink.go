@@ -0,0 +1,168 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournaldSocket is the socket systemd-journald receives entries in
+// its native protocol on.
+var DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// maxJournaldFieldName bounds the length of journal field names.
+const maxJournaldFieldName = 64
+
+// A JournaldSink forwards Entries to systemd-journald in its native
+// protocol, one datagram per entry. The message, tag, pid and tid become
+// MESSAGE, SYSLOG_IDENTIFIER, SYSLOG_PID and TID, the priority is mapped to
+// a syslog severity in PRIORITY. The time the entry was logged is sent as
+// ALOG_REALTIME in microseconds since the epoch, extensions as ALOG_<KEY>.
+//
+// Entries are limited by the max datagram size of the socket, as passing
+// larger entries via memfd is not supported. A JournaldSink is safe for
+// concurrent use.
+type JournaldSink struct {
+	socket string        // Path of journald's socket
+	m      sync.Mutex    // Serializes writes to conn
+	conn   *net.UnixConn // Connection to journald, nil after a failed write
+	buf    bytes.Buffer  // Reused for encoding individual entries
+}
+
+// NewJournaldSink returns a JournaldSink forwarding entries to DefaultJournaldSocket.
+//
+// Returns an error if connecting to journald fails.
+func NewJournaldSink() (*JournaldSink, error) {
+	return NewJournaldSinkForSocket(DefaultJournaldSocket)
+}
+
+// NewJournaldSinkForSocket returns a JournaldSink forwarding entries to the socket at path.
+//
+// Returns an error if connecting to the socket fails.
+func NewJournaldSinkForSocket(path string) (*JournaldSink, error) {
+	self := &JournaldSink{socket: path}
+	if err := self.connect(); err != nil {
+		return nil, err
+	}
+
+	return self, nil
+}
+
+// Close closes the connection to journald.
+func (self *JournaldSink) Close() error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if self.conn == nil {
+		return nil
+	}
+
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
+
+// WriteEntry sends entry to journald, reconnecting once if journald was
+// restarted since the last write.
+//
+// Returns an error if sending fails.
+func (self *JournaldSink) WriteEntry(entry *Entry) error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.buf.Reset()
+	encodeJournaldEntry(&self.buf, entry)
+
+	if self.conn != nil {
+		if _, err := self.conn.Write(self.buf.Bytes()); err == nil {
+			return nil
+		}
+		self.conn.Close()
+		self.conn = nil
+	}
+
+	if err := self.connect(); err != nil {
+		return err
+	}
+
+	_, err := self.conn.Write(self.buf.Bytes())
+	return err
+}
+
+func (self *JournaldSink) connect() error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: self.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+
+	self.conn = conn
+	return nil
+}
+
+// encodeJournaldEntry appends the fields of entry to buf in journald's native protocol.
+func encodeJournaldEntry(buf *bytes.Buffer, entry *Entry) {
+	writeJournaldField(buf, "MESSAGE", entry.Message)
+	writeJournaldField(buf, "PRIORITY", strconv.Itoa(syslogSeverity(entry.Priority)))
+	writeJournaldField(buf, "SYSLOG_IDENTIFIER", string(entry.Tag))
+	writeJournaldField(buf, "SYSLOG_PID", strconv.Itoa(int(entry.Pid)))
+	writeJournaldField(buf, "TID", strconv.Itoa(int(entry.Tid)))
+	writeJournaldField(buf, "ALOG_REALTIME", strconv.FormatInt(entry.When.Time().UnixMicro(), 10))
+
+	keys := make([]string, 0, len(entry.Ext))
+	for k := range entry.Ext {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		writeJournaldField(buf, journaldFieldName("ALOG_"+k), fmt.Sprint(entry.Ext[k]))
+	}
+}
+
+// writeJournaldField appends a single field to buf, using the binary
+// encoding for values spanning multiple lines.
+func writeJournaldField(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name)
+
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+
+	buf.WriteByte('\n')
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journaldFieldName maps s to a valid journal field name, consisting of
+// uppercase letters, digits and underscores.
+func journaldFieldName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+
+	if len(s) > maxJournaldFieldName {
+		s = s[:maxJournaldFieldName]
+	}
+
+	return s
+}
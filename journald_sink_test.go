@@ -0,0 +1,84 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseJournaldFields parses a datagram in journald's native protocol.
+func parseJournaldFields(t *testing.T, b []byte) map[string]string {
+	fields := make(map[string]string)
+
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		require.True(t, i > 0)
+
+		name := string(b[:i])
+		if b[i] == '=' {
+			end := bytes.IndexByte(b, '\n')
+			require.True(t, end > i)
+			fields[name] = string(b[i+1 : end])
+			b = b[end+1:]
+			continue
+		}
+
+		size := int(binary.LittleEndian.Uint64(b[i+1:]))
+		fields[name] = string(b[i+9 : i+9+size])
+		require.Equal(t, byte('\n'), b[i+9+size])
+		b = b[i+10+size:]
+	}
+
+	return fields
+}
+
+func TestJournaldSinkSendsNativeProtocol(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		socket := filepath.Join(dir, "journal")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+		require.NoError(t, err)
+		defer conn.Close()
+
+		sink, err := NewJournaldSinkForSocket(socket)
+		require.NoError(t, err)
+		defer sink.Close()
+
+		n, err := CopyEntries(sink, &sliceReader{entries: []*Entry{&syslogTestEntry}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		b := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err = conn.Read(b)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{
+			"MESSAGE":           "first\nsecond",
+			"PRIORITY":          "3",
+			"SYSLOG_IDENTIFIER": "Test Tag",
+			"SYSLOG_PID":        "42",
+			"TID":               "43",
+			"ALOG_REALTIME":     "1446379994015000",
+			"ALOG_EUID":         "1000",
+			"ALOG_LID":          "system",
+		}, parseJournaldFields(t, b[:n]))
+	})
+}
+
+func TestJournaldFieldNameSanitizesKeys(t *testing.T) {
+	assert.Equal(t, "ALOG_VENDOR_KEY_2", journaldFieldName("ALOG_vendor-key.2"))
+	assert.Len(t, journaldFieldName("ALOG_"+string(bytes.Repeat([]byte("x"), 100))), maxJournaldFieldName)
+}
+
+func TestNewJournaldSinkFailsWithoutJournald(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		_, err := NewJournaldSinkForSocket(filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,277 @@
+package alog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	kmsgTag = Tag("kernel") // Tag of all entries read from the kernel's ring buffer
+)
+
+// DefaultKmsg is the device exposing the kernel's ring buffer.
+var DefaultKmsg = "/dev/kmsg"
+
+// A KmsgReader implements Reader, reading the kernel's messages from the
+// ring buffer exposed via /dev/kmsg. Entries are tagged "kernel" and carry
+// LogIdKernel under key 'lid', the sequence number under key 'seq', the
+// syslog facility under key 'facility', the monotonic timestamp under key
+// 'monotonic' and the key/value pairs of continuation lines, if any, under
+// key 'dict' in their Ext field.
+type KmsgReader struct {
+	f       *os.File      // The file we read records from
+	r       *bufio.Reader // Buffers the lines of individual records
+	regular bool          // Whether f is a regular file rather than the device
+	boot    time.Time     // Wall clock time corresponding to a monotonic timestamp of 0
+	offset  int64         // Offset of the next record
+	lenient bool          // Whether to skip malformed records
+	skipped uint64        // Number of malformed records skipped
+}
+
+// NewKmsgReader returns a KmsgReader reading all messages still available
+// in the kernel's ring buffer, and new messages as they arrive.
+//
+// Returns an error if opening DefaultKmsg fails.
+func NewKmsgReader() (*KmsgReader, error) {
+	return NewKmsgReaderForFile(DefaultKmsg)
+}
+
+// NewKmsgReaderForFile returns a KmsgReader reading records in the format
+// of /dev/kmsg from fn. If fn names a regular file, e.g., a capture of
+// /dev/kmsg, ReadNext returns io.EOF at its end.
+//
+// Returns an error if opening fn fails.
+func NewKmsgReaderForFile(fn string) (*KmsgReader, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &KmsgReader{
+		f:       f,
+		r:       bufio.NewReaderSize(f, 8*1024),
+		regular: fi.Mode().IsRegular(),
+		boot:    time.Now().Add(-monotonicNow()),
+	}, nil
+}
+
+// Close closes the underlying file.
+func (self *KmsgReader) Close() error {
+	return self.f.Close()
+}
+
+// SetDeadline adjusts the deadline for reading for a KmsgReader.
+//
+// Returns an error if the underlying file does not support deadlines.
+func (self *KmsgReader) SetDeadline(t time.Time) error {
+	return self.f.SetReadDeadline(t)
+}
+
+// SetLenient makes self skip malformed records instead of returning a
+// *ParseError, counting them in Skipped.
+func (self *KmsgReader) SetLenient(lenient bool) {
+	self.lenient = lenient
+}
+
+// Skipped returns the number of malformed records skipped by self in lenient mode.
+func (self *KmsgReader) Skipped() uint64 {
+	return self.skipped
+}
+
+// ReadNext reads the next record from the kernel's ring buffer. Records
+// overwritten before they could be read are silently skipped.
+//
+// Returns ErrReadTimeout if the read operation times out.
+// Returns io.EOF at the end of a regular file.
+// Returns a *ParseError if a record is malformed, unless self is lenient.
+// Returns an error if reading fails.
+func (self *KmsgReader) ReadNext() (*Entry, error) {
+	for {
+		line, err := self.readLine()
+		if errors.Is(err, syscall.EPIPE) {
+			continue
+		} else if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, ErrReadTimeout
+		} else if err != nil {
+			return nil, err
+		}
+
+		offset := self.offset
+		self.offset += int64(len(line))
+
+		entry, pe := self.parseRecord(line)
+		if pe != nil {
+			pe.LogId, pe.Offset, pe.Raw = LogIdKernel, offset, append([]byte(nil), line...)
+			if self.lenient {
+				self.skipped++
+				continue
+			}
+			return nil, pe
+		}
+
+		for self.continues() {
+			line, err := self.readLine()
+			if err != nil {
+				break
+			}
+
+			self.offset += int64(len(line))
+			if k, v, ok := bytes.Cut(bytes.TrimSpace(line), []byte("=")); ok {
+				dict, _ := entry.Ext[ExtDict].(map[string]string)
+				if dict == nil {
+					dict = make(map[string]string)
+					entry.Ext[ExtDict] = dict
+				}
+				dict[unescapeKmsg(k)] = unescapeKmsg(v)
+			}
+		}
+
+		return entry, nil
+	}
+}
+
+// readLine returns the next line, including its newline if present. The
+// returned slice is only valid until the next read.
+func (self *KmsgReader) readLine() ([]byte, error) {
+	line, err := self.r.ReadSlice('\n')
+	if err == io.EOF && len(line) > 0 {
+		return line, nil
+	}
+
+	return line, err
+}
+
+// continues returns true if the next line continues the current record. The
+// device hands out complete records per read, such that we must not block
+// waiting for the next one.
+func (self *KmsgReader) continues() bool {
+	if self.r.Buffered() == 0 && !self.regular {
+		return false
+	}
+
+	b, err := self.r.Peek(1)
+	return err == nil && b[0] == ' '
+}
+
+// parseRecord parses the header line of a record, formatted as
+// "prio,seq,usec,flags[,...];message".
+//
+// Returns a *ParseError with kind ErrShortHeader if the header lacks fields.
+// Returns a *ParseError with kind ErrMalformedHeader if the header lacks its
+// terminator or any of its numeric fields does not parse.
+// Log, offset and raw record are left to the caller.
+func (self *KmsgReader) parseRecord(line []byte) (*Entry, *ParseError) {
+	hdr, msg, ok := bytes.Cut(bytes.TrimRight(line, "\n"), []byte(";"))
+	if !ok {
+		return nil, &ParseError{Kind: ErrMalformedHeader, Err: errors.New("Kernel message lacks the header terminator")}
+	}
+
+	fields := bytes.Split(hdr, []byte(","))
+	if len(fields) < 3 {
+		return nil, &ParseError{Kind: ErrShortHeader, Err: fmt.Errorf("Kernel message header has %d fields, expected at least 3", len(fields))}
+	}
+
+	prio, err := strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return nil, &ParseError{Kind: ErrMalformedHeader, Err: err}
+	}
+
+	seq, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return nil, &ParseError{Kind: ErrMalformedHeader, Err: err}
+	}
+
+	usec, err := strconv.ParseInt(string(fields[2]), 10, 64)
+	if err != nil {
+		return nil, &ParseError{Kind: ErrMalformedHeader, Err: err}
+	}
+
+	monotonic := time.Duration(usec) * time.Microsecond
+
+	entry := &Entry{
+		When:     NewTimestamp(self.boot.Add(monotonic)),
+		Priority: kmsgPriority(prio & 7),
+		Tag:      kmsgTag,
+		Message:  unescapeKmsg(bytes.TrimSpace(msg)),
+		Ext: map[string]interface{}{
+			ExtLogId:     LogIdKernel,
+			ExtSeq:       seq,
+			ExtFacility:  prio >> 3,
+			ExtMonotonic: monotonic,
+		},
+	}
+
+	// Kernels built with CONFIG_PRINTK_CALLER name the calling thread, e.g., caller=T42.
+	for _, f := range fields[3:] {
+		if caller, ok := bytes.CutPrefix(f, []byte("caller=T")); ok {
+			if tid, err := strconv.Atoi(string(caller)); err == nil {
+				entry.Tid = int32(tid)
+			}
+		}
+	}
+
+	return entry, nil
+}
+
+// kmsgPriority maps the syslog level of a kernel message to a Priority.
+func kmsgPriority(level int) Priority {
+	switch level {
+	case 0, 1, 2: // emerg, alert, crit
+		return PriorityFatal
+	case 3:
+		return PriorityError
+	case 4:
+		return PriorityWarn
+	case 5, 6: // notice, info
+		return PriorityInfo
+	default:
+		return PriorityDebug
+	}
+}
+
+// unescapeKmsg returns b with the \xNN escape sequences used by the kernel
+// for non-printable bytes replaced by the respective bytes.
+func unescapeKmsg(b []byte) string {
+	if bytes.IndexByte(b, '\\') < 0 {
+		return string(b)
+	}
+
+	result := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\\' && i+3 < len(b) && b[i+1] == 'x' {
+			if c, err := strconv.ParseUint(string(b[i+2:i+4]), 16, 8); err == nil {
+				result = append(result, byte(c))
+				i += 3
+				continue
+			}
+		}
+		result = append(result, b[i])
+	}
+
+	return string(result)
+}
+
+// monotonicNow returns the current reading of CLOCK_MONOTONIC, the clock
+// used by the kernel for timestamping its messages.
+func monotonicNow() time.Duration {
+	var ts syscall.Timespec
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0
+	}
+
+	return time.Duration(ts.Nano())
+}
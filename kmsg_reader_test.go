@@ -0,0 +1,113 @@
+package alog
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKmsg = "6,1024,5000000,-;usb 1-1: new high-speed USB device\n" +
+	" SUBSYSTEM=usb\n" +
+	" DEVICE=c189:1\n" +
+	"3,1025,5500000,-,caller=T42;EXT4-fs error: \\x5cbad\\x09inode\n" +
+	"garbage\n" +
+	"12,1026,6000000,c;cont\n"
+
+func withKmsg(t *testing.T, content string, f func(kr *KmsgReader)) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "kmsg")
+		require.NoError(t, ioutil.WriteFile(fn, []byte(content), 0644))
+
+		kr, err := NewKmsgReaderForFile(fn)
+		require.NoError(t, err)
+		defer kr.Close()
+
+		f(kr)
+	})
+}
+
+func TestKmsgReaderParsesRecords(t *testing.T) {
+	withKmsg(t, testKmsg, func(kr *KmsgReader) {
+		entry, err := kr.ReadNext()
+		require.NoError(t, err)
+
+		assert.Equal(t, PriorityInfo, entry.Priority)
+		assert.Equal(t, Tag("kernel"), entry.Tag)
+		assert.Equal(t, "usb 1-1: new high-speed USB device", entry.Message)
+		assert.Equal(t, LogIdKernel, entry.Ext[ExtLogId])
+		assert.Equal(t, uint64(1024), entry.Ext[ExtSeq])
+		assert.Equal(t, 0, entry.Ext[ExtFacility])
+		assert.Equal(t, 5*time.Second, entry.Ext[ExtMonotonic])
+		assert.Equal(t, map[string]string{"SUBSYSTEM": "usb", "DEVICE": "c189:1"}, entry.Ext[ExtDict])
+
+		next, err := kr.ReadNext()
+		require.NoError(t, err)
+
+		assert.Equal(t, PriorityError, next.Priority)
+		assert.Equal(t, int32(42), next.Tid)
+		assert.Equal(t, "EXT4-fs error: \\bad\tinode", next.Message)
+		assert.Nil(t, next.Ext[ExtDict])
+		assert.Equal(t, 500*time.Millisecond, next.When.Time().Sub(entry.When.Time()))
+
+		_, err = kr.ReadNext()
+		var pe *ParseError
+		require.True(t, errors.As(err, &pe))
+		assert.Equal(t, LogIdKernel, pe.LogId)
+		assert.Equal(t, []byte("garbage\n"), pe.Raw)
+		assert.True(t, errors.Is(err, ErrMalformedHeader))
+
+		last, err := kr.ReadNext()
+		require.NoError(t, err)
+		assert.Equal(t, PriorityWarn, last.Priority)
+		assert.Equal(t, 1, last.Ext[ExtFacility])
+
+		_, err = kr.ReadNext()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestLenientKmsgReaderSkipsMalformedRecords(t *testing.T) {
+	withKmsg(t, testKmsg, func(kr *KmsgReader) {
+		kr.SetLenient(true)
+
+		n := 0
+		for _, err := kr.ReadNext(); err != io.EOF; _, err = kr.ReadNext() {
+			require.NoError(t, err)
+			n++
+		}
+
+		assert.Equal(t, 3, n)
+		assert.Equal(t, uint64(1), kr.Skipped())
+	})
+}
+
+func TestKmsgReaderReportsKindOfMalformedRecords(t *testing.T) {
+	for line, kind := range map[string]error{
+		"6,1024;no usec\n":          ErrShortHeader,
+		"six,1024,5000000,-;msg\n":  ErrMalformedHeader,
+		"6,-1,5000000,-;msg\n":      ErrMalformedHeader,
+		"6,1024,5s,-;msg\n":         ErrMalformedHeader,
+		"6,1024,5000000,- no msg\n": ErrMalformedHeader,
+	} {
+		withKmsg(t, line, func(kr *KmsgReader) {
+			_, err := kr.ReadNext()
+			assert.True(t, errors.Is(err, kind), line)
+		})
+	}
+}
+
+func TestKmsgPriorityMapsSyslogLevels(t *testing.T) {
+	assert.Equal(t, PriorityFatal, kmsgPriority(0))
+	assert.Equal(t, PriorityFatal, kmsgPriority(2))
+	assert.Equal(t, PriorityError, kmsgPriority(3))
+	assert.Equal(t, PriorityWarn, kmsgPriority(4))
+	assert.Equal(t, PriorityInfo, kmsgPriority(5))
+	assert.Equal(t, PriorityInfo, kmsgPriority(6))
+	assert.Equal(t, PriorityDebug, kmsgPriority(7))
+}
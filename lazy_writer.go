@@ -0,0 +1,150 @@
+package alog
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Discard is a Writer that silently drops all log messages.
+var Discard Writer = discardWriter{}
+
+type discardWriter struct{}
+
+func (discardWriter) Close() error                      { return nil }
+func (discardWriter) SetDeadline(t time.Time) error     { return nil }
+func (discardWriter) Write(Priority, Tag, string) error { return nil }
+
+// fallback guards the Writer that LazyWriter instances fall back to.
+var fallback = struct {
+	sync.Mutex
+	w Writer
+}{w: NewTextWriter(os.Stderr)}
+
+// SetFallback adjusts the Writer that LazyWriter instances send log messages
+// to if accessing Android's logging facilities fails. Passing nil selects
+// Discard. By default, messages are written to stderr in logcat's threadtime format.
+//
+// Returns the previously configured fallback Writer.
+func SetFallback(w Writer) Writer {
+	if w == nil {
+		w = Discard
+	}
+
+	fallback.Lock()
+	defer fallback.Unlock()
+
+	prev := fallback.w
+	fallback.w = w
+	return prev
+}
+
+// Fallback returns the Writer that LazyWriter instances currently fall back to.
+func Fallback() Writer {
+	fallback.Lock()
+	defer fallback.Unlock()
+
+	return fallback.w
+}
+
+// A LazyWriter implements Writer, connecting to Android's logging facilities
+// on first use. If connecting fails, all calls are forwarded to the Writer
+// configured via SetFallback.
+type LazyWriter struct {
+	id        LogId                  // The log we connect to
+	open      func() (Writer, error) // Connects to the log, called at most once
+	once      sync.Once              // Guards the call to open
+	connected atomic.Bool            // Whether open succeeded
+	w         Writer                 // Connection to the log, nil if open failed
+	err       error                  // Error returned by open
+}
+
+// NewLazyWriter returns a LazyWriter that connects to the log identified by id
+// on first use.
+func NewLazyWriter(id LogId) *LazyWriter {
+	return newLazyWriter(id, func() (Writer, error) {
+		w, err := NewLoggerWriter(id)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	})
+}
+
+func newLazyWriter(id LogId, open func() (Writer, error)) *LazyWriter {
+	return &LazyWriter{id: id, open: open}
+}
+
+// writer connects to the log if not done before, returning either the
+// connection or the current fallback Writer.
+func (self *LazyWriter) writer() Writer {
+	self.once.Do(func() {
+		self.w, self.err = self.open()
+		self.connected.Store(self.err == nil)
+	})
+
+	if self.err != nil {
+		return Fallback()
+	}
+
+	return self.w
+}
+
+// Err connects to the log if not done before, returning the error that
+// occurred when connecting to Android's logging facilities (if any).
+// A non-nil error indicates that self writes to the fallback Writer.
+func (self *LazyWriter) Err() error {
+	self.writer()
+	return self.err
+}
+
+// LogId returns the id of the log self connects to.
+func (self *LazyWriter) LogId() LogId {
+	return self.id
+}
+
+// Close shuts down the connection to Android's logging facilities, if
+// established. The fallback Writer is shared and never closed.
+func (self *LazyWriter) Close() error {
+	if !self.connected.Load() {
+		return nil
+	}
+
+	return self.w.Close()
+}
+
+// SetDeadline forwards the call to the underlying Writer.
+func (self *LazyWriter) SetDeadline(t time.Time) error {
+	return self.writer().SetDeadline(t)
+}
+
+// Write logs message with prio and tag, either to Android's logging
+// facilities or to the fallback Writer.
+func (self *LazyWriter) Write(prio Priority, tag Tag, message string) error {
+	return self.writer().Write(prio, tag, message)
+}
+
+func (self *LazyWriter) V(tag Tag, message string) error {
+	return self.Write(PriorityVerbose, tag, message)
+}
+
+func (self *LazyWriter) D(tag Tag, message string) error {
+	return self.Write(PriorityDebug, tag, message)
+}
+
+func (self *LazyWriter) I(tag Tag, message string) error {
+	return self.Write(PriorityInfo, tag, message)
+}
+
+func (self *LazyWriter) W(tag Tag, message string) error {
+	return self.Write(PriorityWarn, tag, message)
+}
+
+func (self *LazyWriter) E(tag Tag, message string) error {
+	return self.Write(PriorityError, tag, message)
+}
+
+func (self *LazyWriter) F(tag Tag, message string) error {
+	return self.Write(PriorityFatal, tag, message)
+}
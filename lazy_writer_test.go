@@ -0,0 +1,94 @@
+package alog
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLazyWriterConnectsOnlyOnce(t *testing.T) {
+	mw := &MockWriter{}
+	mw.On("Write", PriorityDebug, testTag, "42").Return(nil)
+
+	calls := 0
+	lw := newLazyWriter(LogIdMain, func() (Writer, error) {
+		calls++
+		return mw, nil
+	})
+
+	assert.Equal(t, 0, calls)
+	assert.NoError(t, lw.D(testTag, "42"))
+	assert.NoError(t, lw.D(testTag, "42"))
+	assert.NoError(t, lw.Err())
+	assert.Equal(t, 1, calls)
+
+	mw.AssertNumberOfCalls(t, "Write", 2)
+}
+
+func TestLazyWriterClosesWithoutConnecting(t *testing.T) {
+	calls := 0
+	lw := newLazyWriter(LogIdMain, func() (Writer, error) {
+		calls++
+		return &MockWriter{}, nil
+	})
+
+	assert.NoError(t, lw.Close())
+	assert.Equal(t, 0, calls)
+}
+
+func TestLazyWriterFallsBackIfConnectingFails(t *testing.T) {
+	mw := &MockWriter{}
+	mw.On("Write", PriorityInfo, testTag, "42").Return(nil)
+
+	prev := SetFallback(mw)
+	defer SetFallback(prev)
+
+	errNoLog := errors.New("no log")
+	lw := newLazyWriter(LogIdRadio, func() (Writer, error) {
+		return nil, errNoLog
+	})
+
+	assert.NoError(t, I(lw, testTag, "42"))
+	assert.Equal(t, errNoLog, lw.Err())
+	assert.NoError(t, lw.Close())
+
+	mw.AssertExpectations(t)
+}
+
+func TestLazyWriterPicksUpFallbackChanges(t *testing.T) {
+	lw := newLazyWriter(LogIdEvents, func() (Writer, error) {
+		return nil, errors.New("no log")
+	})
+
+	prev := SetFallback(nil)
+	defer SetFallback(prev)
+
+	assert.NoError(t, lw.W(testTag, "dropped"))
+
+	mw := &MockWriter{}
+	mw.On("Write", PriorityWarn, testTag, "42").Return(nil)
+	SetFallback(mw)
+
+	assert.NoError(t, lw.W(testTag, "42"))
+	mw.AssertExpectations(t)
+}
+
+func TestSetFallbackReturnsPreviousWriter(t *testing.T) {
+	prev := SetFallback(Discard)
+	defer SetFallback(prev)
+
+	assert.Equal(t, Discard, Fallback())
+	assert.Equal(t, Discard, SetFallback(prev))
+	assert.Equal(t, prev, Fallback())
+}
+
+func TestGlobalWritersDoNotPanicWithoutAndroidLoggingFacilities(t *testing.T) {
+	prev := SetFallback(Discard)
+	defer SetFallback(prev)
+
+	assert.NoError(t, D(Main, testTag, "42"))
+	assert.NoError(t, D(Radio, testTag, "42"))
+	assert.NoError(t, D(Events, testTag, "42"))
+	assert.NoError(t, D(System, testTag, "42"))
+}
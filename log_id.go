@@ -1,5 +1,7 @@
 package alog
 
+import "fmt"
+
 // A LogId uniquely names a log stream.
 type LogId int
 
@@ -9,6 +11,9 @@ const (
 	LogIdEvents LogId = 2
 	LogIdSystem LogId = 3
 	LogIdCrash  LogId = 4
+	LogIdKernel LogId = 7 // The kernel's ring buffer, see KmsgReader
+
+	LogIdUnknown LogId = -1 // Placeholder for entries of unknown origin
 )
 
 // String returns the name of a LogId
@@ -24,7 +29,24 @@ func (self LogId) String() string {
 		return "system"
 	case LogIdCrash:
 		return "crash"
+	case LogIdKernel:
+		return "kernel"
+	case LogIdUnknown:
+		return "unknown"
 	default:
 		return "main"
 	}
 }
+
+// ParseLogId returns the LogId named s.
+//
+// Returns an error if s does not name a known log.
+func ParseLogId(s string) (LogId, error) {
+	for _, id := range []LogId{LogIdMain, LogIdRadio, LogIdEvents, LogIdSystem, LogIdCrash, LogIdKernel} {
+		if id.String() == s {
+			return id, nil
+		}
+	}
+
+	return LogIdMain, fmt.Errorf("Unknown log: %s", s)
+}
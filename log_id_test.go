@@ -12,6 +12,7 @@ func TestLogIdConstantValues(t *testing.T) {
 	assert.EqualValues(t, 2, LogIdEvents)
 	assert.EqualValues(t, 3, LogIdSystem)
 	assert.EqualValues(t, 4, LogIdCrash)
+	assert.EqualValues(t, 7, LogIdKernel)
 }
 
 func TestLogIdStringReturnsCorrectValues(t *testing.T) {
@@ -20,6 +21,18 @@ func TestLogIdStringReturnsCorrectValues(t *testing.T) {
 	assert.Equal(t, "events", LogIdEvents.String())
 	assert.Equal(t, "system", LogIdSystem.String())
 	assert.Equal(t, "crash", LogIdCrash.String())
+	assert.Equal(t, "kernel", LogIdKernel.String())
 
 	assert.Equal(t, "main", LogId(42).String())
 }
+
+func TestParseLogIdRoundTrips(t *testing.T) {
+	for _, id := range []LogId{LogIdMain, LogIdRadio, LogIdEvents, LogIdSystem, LogIdCrash, LogIdKernel} {
+		parsed, err := ParseLogId(id.String())
+		assert.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	}
+
+	_, err := ParseLogId("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,372 @@
+package alog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A Logd names the unix sockets exposed by logd, the logging daemon that
+// replaces the kernel logger from Lollipop onwards.
+type Logd struct {
+	Dir string // Directory containing the sockets logdr, logdw and logd
+}
+
+// DefaultLogd refers to the system's logd instance.
+var DefaultLogd = &Logd{Dir: filepath.Join("/dev", "socket")}
+
+// ReaderSocket returns the path of the socket for reading entries.
+func (self *Logd) ReaderSocket() string {
+	return filepath.Join(self.Dir, "logdr")
+}
+
+// WriterSocket returns the path of the socket for writing entries.
+func (self *Logd) WriterSocket() string {
+	return filepath.Join(self.Dir, "logdw")
+}
+
+// ControlSocket returns the path of the socket accepting control commands.
+func (self *Logd) ControlSocket() string {
+	return filepath.Join(self.Dir, "logd")
+}
+
+// Available returns true if the sockets of self exist.
+func (self *Logd) Available() bool {
+	_, err := os.Stat(self.ReaderSocket())
+	return err == nil
+}
+
+// command sends cmd over the control socket and returns logd's response.
+//
+// Returns an error if talking to logd fails.
+func (self *Logd) command(cmd string) (string, error) {
+	conn, err := net.Dial("unix", self.ControlSocket())
+	if err != nil {
+		return "", err
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.Write(append([]byte(cmd), 0)); err != nil {
+		return "", err
+	}
+
+	var response []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+
+		if i := strings.IndexByte(string(response), 0); i >= 0 {
+			return string(response[:i]), nil
+		} else if err == io.EOF {
+			return string(response), nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// intCommand sends cmd over the control socket, parsing the response as an integer.
+//
+// Returns an error if talking to logd fails or the response is not an integer.
+func (self *Logd) intCommand(cmd string) (int, error) {
+	response, err := self.command(cmd)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(response))
+	if err != nil {
+		return 0, fmt.Errorf("Unexpected response from logd to %q: %s", cmd, response)
+	}
+
+	return n, nil
+}
+
+// Clear discards all entries of the log identified by id.
+//
+// Returns an error if talking to logd fails or logd rejects the request.
+func (self *Logd) Clear(id LogId) error {
+	response, err := self.command(fmt.Sprintf("clear %d", id))
+	if err != nil {
+		return err
+	}
+
+	if response != "success" {
+		return fmt.Errorf("Failed to clear log %s: %s", id, response)
+	}
+
+	return nil
+}
+
+// LogSize returns the capacity in bytes of the log identified by id.
+//
+// Returns an error if talking to logd fails.
+func (self *Logd) LogSize(id LogId) (int, error) {
+	return self.intCommand(fmt.Sprintf("getLogSize %d", id))
+}
+
+// LogSizeUsed returns the number of bytes currently used by the log identified by id.
+//
+// Returns an error if talking to logd fails.
+func (self *Logd) LogSizeUsed(id LogId) (int, error) {
+	return self.intCommand(fmt.Sprintf("getLogSizeUsed %d", id))
+}
+
+// A LogdQuery selects the entries a LogdReader receives.
+type LogdQuery struct {
+	LogIds []LogId   // The logs to read from, all logs if empty
+	Tail   int       // Only read the most recent Tail entries if > 0
+	Start  time.Time // Only read entries logged after Start if not zero
+	Pid    int32     // Only read entries logged by Pid if > 0
+	Dump   bool      // Stop reading once all available entries have been read
+}
+
+// String returns self in the syntax understood by logd's reader socket.
+func (self LogdQuery) String() string {
+	parts := []string{"stream"}
+	if self.Dump {
+		parts[0] = "dumpAndClose"
+	}
+
+	if len(self.LogIds) > 0 {
+		lids := make([]string, 0, len(self.LogIds))
+		for _, id := range self.LogIds {
+			lids = append(lids, strconv.Itoa(int(id)))
+		}
+		parts = append(parts, "lids="+strings.Join(lids, ","))
+	}
+
+	if self.Tail > 0 {
+		parts = append(parts, fmt.Sprintf("tail=%d", self.Tail))
+	}
+
+	if !self.Start.IsZero() {
+		parts = append(parts, fmt.Sprintf("start=%d.%09d", self.Start.Unix(), self.Start.Nanosecond()))
+	}
+
+	if self.Pid > 0 {
+		parts = append(parts, fmt.Sprintf("pid=%d", self.Pid))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// A LogdAbiExtension implements LoggerAbiExtension, reading the additional
+// header fields of logger_entry_v3 (lid) and logger_entry_v4 (uid) as sent by logd.
+type LogdAbiExtension struct {
+}
+
+// Read unmarshals the lid field and, if present, the uid field from reader,
+// returning them in the extension map under keys 'lid' and 'uid'.
+//
+// Returns an error if unmarshaling the lid field from reader fails.
+func (self LogdAbiExtension) Read(reader io.Reader) (map[string]interface{}, error) {
+	lid := uint32(0)
+	if err := binary.Read(reader, binary.LittleEndian, &lid); err != nil {
+		return nil, err
+	}
+
+	ext := map[string]interface{}{ExtLogId: LogId(lid)}
+
+	uid := uint32(0)
+	if err := binary.Read(reader, binary.LittleEndian, &uid); err == nil {
+		ext[ExtUid] = uid
+	} else if err != io.EOF {
+		return nil, err
+	}
+
+	return ext, nil
+}
+
+// Schema declares the lid and uid fields.
+func (self LogdAbiExtension) Schema() map[string]reflect.Type {
+	return map[string]reflect.Type{
+		ExtLogId: reflect.TypeOf(LogId(0)),
+		ExtUid:   reflect.TypeOf(uint32(0)),
+	}
+}
+
+// A LogdReader implements Reader, receiving entries from logd.
+type LogdReader struct {
+	conn   *net.UnixConn // Our connection to logd's reader socket
+	parser recordParser  // Parses received entries
+	buf    []byte        // Buffer for receiving individual entries
+}
+
+// NewLogdReader returns a LogdReader receiving the entries selected by query
+// from the system's logd instance.
+//
+// Returns an error if connecting to logd fails.
+func NewLogdReader(query LogdQuery) (*LogdReader, error) {
+	return DefaultLogd.NewReader(query)
+}
+
+// NewReader returns a LogdReader receiving the entries selected by query from self.
+//
+// Returns an error if connecting to logd fails.
+func (self *Logd) NewReader(query LogdQuery) (*LogdReader, error) {
+	conn, err := net.DialUnix("unixpacket", nil, &net.UnixAddr{Name: self.ReaderSocket(), Net: "unixpacket"})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = conn.Write([]byte(query.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	id := LogIdUnknown
+	if len(query.LogIds) == 1 {
+		id = query.LogIds[0]
+	}
+
+	return &LogdReader{conn: conn, parser: recordParser{abiExtension: LogdAbiExtension{}, id: id}, buf: make([]byte, maxEntrySize, maxEntrySize)}, nil
+}
+
+// Close closes the connection to logd.
+func (self *LogdReader) Close() error {
+	return self.conn.Close()
+}
+
+// SetDeadline adjusts the deadline for reading for a LogdReader.
+func (self *LogdReader) SetDeadline(t time.Time) error {
+	return self.conn.SetReadDeadline(t)
+}
+
+// SetTagTable makes self intern the tags of all entries received in tags.
+// Passing nil disables interning.
+func (self *LogdReader) SetTagTable(tags *TagTable) {
+	self.parser.tags = tags
+}
+
+// SetRawMessages makes self preserve the exact message bytes of all entries
+// received in Entry.RawMessage, including leading and trailing whitespace and
+// invalid UTF-8 sequences. Entry.Message remains available as a trimmed view.
+func (self *LogdReader) SetRawMessages(raw bool) {
+	self.parser.raw = raw
+}
+
+// SetLenient makes self skip malformed entries instead of returning a
+// *ParseError, counting them in Skipped.
+func (self *LogdReader) SetLenient(lenient bool) {
+	self.parser.lenient = lenient
+}
+
+// Skipped returns the number of malformed entries skipped by self in lenient mode.
+func (self *LogdReader) Skipped() uint64 {
+	return self.parser.skipped
+}
+
+// ReadNext receives the next entry from logd. The log id and, if available,
+// the uid of the writer are placed into the Ext field of Entry under keys
+// 'lid' and 'uid'.
+//
+// Returns io.EOF once logd has sent all entries of a dump.
+// Returns ErrReadTimeout if the read operation times out.
+// Returns a *ParseError if the entry is malformed, unless self is lenient.
+// Returns an error if receiving from logd fails.
+func (self *LogdReader) ReadNext() (*Entry, error) {
+	entry := &Entry{}
+	if err := self.ReadInto(entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// ReadInto is like ReadNext but receives the next entry into entry,
+// overwriting all of its fields.
+//
+// Returns the same errors as ReadNext.
+func (self *LogdReader) ReadInto(entry *Entry) error {
+	for {
+		n, err := self.conn.Read(self.buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return ErrReadTimeout
+			}
+			return err
+		}
+
+		if n == 0 {
+			return io.EOF
+		}
+
+		if err := self.parser.parse(self.buf[:n], entry); !self.parser.skip(err) {
+			return err
+		}
+	}
+}
+
+// ReadBatch receives all immediately available entries into entries, up to
+// len(entries), and returns the number of entries received. ReadBatch waits
+// for the first entry until the deadline set by SetDeadline expires, and
+// drains the socket without waiting from there on, ending the batch early
+// if the deadline expires meanwhile. Entries are overwritten as with ReadInto.
+//
+// Returns the same errors as ReadNext for the first entry. Returns the
+// number of entries received so far together with the error if receiving
+// any subsequent entry fails.
+func (self *LogdReader) ReadBatch(entries []Entry) (int, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	if err := self.ReadInto(&entries[0]); err != nil {
+		return 0, err
+	}
+
+	raw, err := self.conn.SyscallConn()
+	if err != nil {
+		return 1, err
+	}
+
+	n, err := self.drain(raw, entries[1:])
+	return 1 + n, err
+}
+
+// drain receives the entries immediately available from raw into entries,
+// up to len(entries), and returns the number of entries received. Running
+// out of entries or into the deadline ends draining.
+func (self *LogdReader) drain(raw syscall.RawConn, entries []Entry) (int, error) {
+	n := 0
+	for n < len(entries) {
+		var size int
+		var rerr error
+
+		if err := raw.Read(func(fd uintptr) bool {
+			size, _, rerr = syscall.Recvfrom(int(fd), self.buf, syscall.MSG_DONTWAIT)
+			return true
+		}); errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		} else if err != nil {
+			return n, err
+		}
+
+		if rerr == syscall.EAGAIN || size == 0 {
+			break
+		} else if rerr != nil {
+			return n, rerr
+		}
+
+		if err := self.parser.parse(self.buf[:size], &entries[n]); err == nil {
+			n++
+		} else if !self.parser.skip(err) {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,60 @@
+package alog
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogdQueryRendersReaderCommand(t *testing.T) {
+	assert.Equal(t, "stream", LogdQuery{}.String())
+	assert.Equal(t, "dumpAndClose lids=0,3 tail=10 pid=42", LogdQuery{
+		LogIds: []LogId{LogIdMain, LogIdSystem},
+		Tail:   10,
+		Pid:    42,
+		Dump:   true,
+	}.String())
+	assert.Equal(t, "stream start=1446319710.000000042", LogdQuery{Start: time.Unix(1446319710, 42)}.String())
+}
+
+func TestLogdAbiExtensionReadsLidAndOptionalUid(t *testing.T) {
+	ext, err := LogdAbiExtension{}.Read(bytes.NewReader([]byte{3, 0, 0, 0}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{ExtLogId: LogIdSystem}, ext)
+
+	ext, err = LogdAbiExtension{}.Read(bytes.NewReader([]byte{3, 0, 0, 0, 0xe8, 0x03, 0, 0}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{ExtLogId: LogIdSystem, ExtUid: uint32(1000)}, ext)
+
+	_, err = LogdAbiExtension{}.Read(bytes.NewReader(nil))
+	assert.Error(t, err)
+}
+
+func TestLogdSocketsLiveInDir(t *testing.T) {
+	logd := &Logd{Dir: "/tmp"}
+
+	assert.Equal(t, "/tmp/logdr", logd.ReaderSocket())
+	assert.Equal(t, "/tmp/logdw", logd.WriterSocket())
+	assert.Equal(t, "/tmp/logd", logd.ControlSocket())
+	assert.False(t, logd.Available())
+}
+
+// An expiredRawConn implements syscall.RawConn for a connection whose
+// deadline expired.
+type expiredRawConn struct{}
+
+func (expiredRawConn) Control(f func(fd uintptr)) error           { return nil }
+func (expiredRawConn) Read(f func(fd uintptr) (done bool)) error  { return os.ErrDeadlineExceeded }
+func (expiredRawConn) Write(f func(fd uintptr) (done bool)) error { return os.ErrDeadlineExceeded }
+
+func TestLogdReaderEndsBatchOnExpiredDeadline(t *testing.T) {
+	lr := &LogdReader{parser: recordParser{abiExtension: LogdAbiExtension{}}, buf: make([]byte, maxEntrySize)}
+
+	n, err := lr.drain(expiredRawConn{}, make([]Entry, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
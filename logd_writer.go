@@ -0,0 +1,72 @@
+package alog
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	logdHeaderSize = 11 // Size of android_log_header_t: log id, tid and realtime
+)
+
+// A LogdWriter implements Writer, sending log entries to logd.
+type LogdWriter struct {
+	id   LogId         // The log we write to
+	conn *net.UnixConn // Our connection to logd's writer socket
+}
+
+// NewLogdWriter returns a LogdWriter sending entries to the log identified
+// by id of the system's logd instance.
+//
+// Returns an error if connecting to logd fails.
+func NewLogdWriter(id LogId) (*LogdWriter, error) {
+	return DefaultLogd.NewWriter(id)
+}
+
+// NewWriter returns a LogdWriter sending entries to the log identified by id of self.
+//
+// Returns an error if connecting to logd fails.
+func (self *Logd) NewWriter(id LogId) (*LogdWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: self.WriterSocket(), Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogdWriter{id: id, conn: conn}, nil
+}
+
+// Close shuts down the connection to logd.
+func (self *LogdWriter) Close() error {
+	return self.conn.Close()
+}
+
+// SetDeadline adjusts the deadline for writing for a LogdWriter.
+func (self *LogdWriter) SetDeadline(t time.Time) error {
+	return self.conn.SetWriteDeadline(t)
+}
+
+// Write sends a log entry with prio, tag and message to logd, stamped with
+// the current time and thread id. logd determines pid and uid from the
+// credentials of the connection.
+//
+// Returns an error if sending to logd fails.
+func (self *LogdWriter) Write(prio Priority, tag Tag, message string) error {
+	now := time.Now()
+
+	b := make([]byte, logdHeaderSize, logdHeaderSize+1+len(tag)+1+len(message)+1)
+	b[0] = byte(self.id)
+	binary.LittleEndian.PutUint16(b[1:], uint16(syscall.Gettid()))
+	binary.LittleEndian.PutUint32(b[3:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(b[7:], uint32(now.Nanosecond()))
+
+	b = append(b, byte(prio))
+	b = append(b, tag...)
+	b = append(b, 0)
+	b = append(b, message...)
+	b = append(b, 0)
+
+	_, err := self.conn.Write(b)
+	return err
+}
@@ -0,0 +1,610 @@
+// Package logdtest provides an in-process emulation of Android's logd,
+// serving the logdr, logdw and logd socket protocols over unix sockets
+// in a directory of choice. Entries are kept in memory, in one ring buffer
+// per log. It enables integration tests of alog's logd support on Linux hosts.
+package logdtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vosst/alog"
+)
+
+const (
+	DefaultBufferSize = 256 * 1024 // Default capacity in bytes of every log
+
+	logdHeaderSize = 11   // Size of android_log_header_t: log id, tid and realtime
+	entryV4Size    = 28   // Size of the logger_entry_v4 header sent to readers
+	maxPacketSize  = 5120 // Maximum size of a single entry, as in LOGGER_ENTRY_MAX_LEN
+	readerQueue    = 1024 // Number of entries queued per streaming reader
+)
+
+// logIds enumerates all logs emulated by a Server.
+var logIds = []alog.LogId{alog.LogIdMain, alog.LogIdRadio, alog.LogIdEvents, alog.LogIdSystem, alog.LogIdCrash, alog.LogIdKernel}
+
+// A Config bundles the options of a Server.
+type Config struct {
+	Dir        string // Directory to create the sockets in, a new temporary directory if empty
+	BufferSize int    // Capacity in bytes of every log, DefaultBufferSize if 0
+}
+
+// A record is a single entry stored in a ring buffer.
+type record struct {
+	seq   uint64     // Global sequence number, orders entries across logs
+	lid   alog.LogId // The log the entry belongs to
+	uid   uint32     // The uid of the writer
+	entry alog.Entry // The entry itself
+}
+
+// size returns the number of bytes r occupies in a ring buffer.
+func (r *record) size() int {
+	return entryV4Size + 1 + len(r.entry.Tag) + 1 + len(r.entry.Message) + 1
+}
+
+// A ring holds the records of a single log, dropping the oldest ones once
+// the configured capacity is exceeded.
+type ring struct {
+	capacity int
+	used     int
+	records  []*record
+}
+
+func (r *ring) push(rec *record) {
+	r.records = append(r.records, rec)
+	r.used += rec.size()
+
+	for r.used > r.capacity && len(r.records) > 1 {
+		r.used -= r.records[0].size()
+		r.records = r.records[1:]
+	}
+}
+
+func (r *ring) clear() {
+	r.records = nil
+	r.used = 0
+}
+
+// A query mirrors the options understood by logd's reader socket.
+type query struct {
+	dump  bool
+	lids  map[alog.LogId]bool
+	tail  int
+	start time.Time
+	pid   int32
+}
+
+// parseQuery parses a command sent to the reader socket.
+func parseQuery(cmd string) query {
+	q := query{}
+
+	for _, field := range strings.Fields(cmd) {
+		switch {
+		case field == "dumpAndClose":
+			q.dump = true
+		case strings.HasPrefix(field, "lids="):
+			q.lids = make(map[alog.LogId]bool)
+			for _, lid := range strings.Split(field[len("lids="):], ",") {
+				if n, err := strconv.Atoi(lid); err == nil {
+					q.lids[alog.LogId(n)] = true
+				}
+			}
+		case strings.HasPrefix(field, "tail="):
+			q.tail, _ = strconv.Atoi(field[len("tail="):])
+		case strings.HasPrefix(field, "start="):
+			var sec, nsec int64
+			fmt.Sscanf(field[len("start="):], "%d.%d", &sec, &nsec)
+			q.start = time.Unix(sec, nsec)
+		case strings.HasPrefix(field, "pid="):
+			pid, _ := strconv.Atoi(field[len("pid="):])
+			q.pid = int32(pid)
+		}
+	}
+
+	return q
+}
+
+// match returns true if rec is selected by q.
+func (q *query) match(rec *record) bool {
+	if q.lids != nil && !q.lids[rec.lid] {
+		return false
+	}
+
+	if q.pid > 0 && rec.entry.Pid != q.pid {
+		return false
+	}
+
+	return q.start.IsZero() || rec.entry.When.Time().After(q.start)
+}
+
+// A subscriber is a streaming reader waiting for new records.
+type subscriber struct {
+	q       query
+	records chan *record
+}
+
+// A Server emulates logd.
+type Server struct {
+	Logd *alog.Logd // Names the sockets served by the Server
+
+	tempDir bool // Whether Logd.Dir was created by the Server
+	readers *net.UnixListener
+	writer  *net.UnixConn
+	control *net.UnixListener
+	wg      sync.WaitGroup
+
+	m           sync.Mutex
+	seq         uint64
+	rings       map[alog.LogId]*ring
+	subscribers map[*subscriber]bool
+	conns       map[net.Conn]bool
+	closed      bool
+}
+
+// NewServer starts a Server configured by config.
+//
+// Returns an error if creating any of the sockets fails.
+func NewServer(config Config) (*Server, error) {
+	self := &Server{
+		rings:       make(map[alog.LogId]*ring),
+		subscribers: make(map[*subscriber]bool),
+		conns:       make(map[net.Conn]bool),
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultBufferSize
+	}
+
+	for _, id := range logIds {
+		self.rings[id] = &ring{capacity: config.BufferSize}
+	}
+
+	if config.Dir == "" {
+		dir, err := ioutil.TempDir("", "logd")
+		if err != nil {
+			return nil, err
+		}
+		config.Dir, self.tempDir = dir, true
+	}
+
+	self.Logd = &alog.Logd{Dir: config.Dir}
+
+	if err := self.listen(); err != nil {
+		self.Close()
+		return nil, err
+	}
+
+	self.wg.Add(3)
+	go self.serveReaders()
+	go self.serveWriter()
+	go self.serveControl()
+
+	return self, nil
+}
+
+// listen creates all sockets, enabling credentials passing on the writer socket.
+func (self *Server) listen() error {
+	var err error
+
+	if self.readers, err = net.ListenUnix("unixpacket", &net.UnixAddr{Name: self.Logd.ReaderSocket(), Net: "unixpacket"}); err != nil {
+		return err
+	}
+
+	if self.writer, err = net.ListenUnixgram("unixgram", &net.UnixAddr{Name: self.Logd.WriterSocket(), Net: "unixgram"}); err != nil {
+		return err
+	}
+
+	rc, err := self.writer.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	if err = rc.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	}); err != nil {
+		return err
+	}
+
+	if serr != nil {
+		return serr
+	}
+
+	self.control, err = net.ListenUnix("unix", &net.UnixAddr{Name: self.Logd.ControlSocket(), Net: "unix"})
+	return err
+}
+
+// Close shuts down all sockets and waits for all connections to terminate.
+// The socket directory is removed if it was created by NewServer.
+func (self *Server) Close() error {
+	self.m.Lock()
+	self.closed = true
+	for conn := range self.conns {
+		conn.Close()
+	}
+	for s := range self.subscribers {
+		close(s.records)
+		delete(self.subscribers, s)
+	}
+	self.m.Unlock()
+
+	if self.readers != nil {
+		self.readers.Close()
+	}
+
+	if self.writer != nil {
+		self.writer.Close()
+	}
+
+	if self.control != nil {
+		self.control.Close()
+	}
+
+	self.wg.Wait()
+
+	if self.tempDir {
+		return os.RemoveAll(self.Logd.Dir)
+	}
+
+	for _, fn := range []string{self.Logd.ReaderSocket(), self.Logd.WriterSocket(), self.Logd.ControlSocket()} {
+		os.Remove(fn)
+	}
+
+	return nil
+}
+
+// track registers conn for being closed by Close, returning false if the
+// Server is already closed.
+func (self *Server) track(conn net.Conn) bool {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if self.closed {
+		return false
+	}
+
+	self.conns[conn] = true
+	return true
+}
+
+func (self *Server) untrack(conn net.Conn) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	delete(self.conns, conn)
+}
+
+// Append stores entry in the log identified by id as if it was written
+// by a process with uid, notifying all streaming readers.
+func (self *Server) Append(id alog.LogId, uid uint32, entry alog.Entry) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	r, ok := self.rings[id]
+	if !ok || self.closed {
+		return
+	}
+
+	self.seq++
+	rec := &record{seq: self.seq, lid: id, uid: uid, entry: entry}
+	r.push(rec)
+
+	for s := range self.subscribers {
+		if !s.q.match(rec) {
+			continue
+		}
+
+		select {
+		case s.records <- rec:
+		default: // Slow readers miss entries, just like with logd.
+		}
+	}
+}
+
+// Entries returns a copy of all entries currently stored in the log identified by id.
+func (self *Server) Entries(id alog.LogId) []alog.Entry {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	var entries []alog.Entry
+	if r, ok := self.rings[id]; ok {
+		for _, rec := range r.records {
+			entries = append(entries, rec.entry)
+		}
+	}
+
+	return entries
+}
+
+// serveWriter receives entries from the writer socket.
+func (self *Server) serveWriter() {
+	defer self.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofUcred))
+
+	for {
+		n, oobn, _, _, err := self.writer.ReadMsgUnix(buf, oob)
+		if err != nil {
+			return
+		}
+
+		var pid int32
+		var uid uint32
+
+		if msgs, err := syscall.ParseSocketControlMessage(oob[:oobn]); err == nil {
+			for _, msg := range msgs {
+				if cred, err := syscall.ParseUnixCredentials(&msg); err == nil {
+					pid, uid = cred.Pid, cred.Uid
+				}
+			}
+		}
+
+		if id, entry, ok := parsePacket(buf[:n], pid); ok {
+			self.Append(id, uid, entry)
+		}
+	}
+}
+
+// parsePacket parses a packet received on the writer socket.
+func parsePacket(b []byte, pid int32) (alog.LogId, alog.Entry, bool) {
+	if len(b) < logdHeaderSize+3 {
+		return 0, alog.Entry{}, false
+	}
+
+	payload := b[logdHeaderSize:]
+	tagEnd := bytes.IndexByte(payload[1:], 0)
+	if tagEnd < 0 {
+		return 0, alog.Entry{}, false
+	}
+
+	message := payload[tagEnd+2:]
+	if i := bytes.IndexByte(message, 0); i >= 0 {
+		message = message[:i]
+	}
+
+	return alog.LogId(b[0]), alog.Entry{
+		Pid: pid,
+		Tid: int32(binary.LittleEndian.Uint16(b[1:])),
+		When: alog.Timestamp{
+			Seconds:     int32(binary.LittleEndian.Uint32(b[3:])),
+			Nanoseconds: int32(binary.LittleEndian.Uint32(b[7:])),
+		},
+		Priority: alog.Priority(payload[0]),
+		Tag:      alog.Tag(payload[1 : tagEnd+1]),
+		Message:  string(message),
+	}, true
+}
+
+// serveReaders accepts connections on the reader socket.
+func (self *Server) serveReaders() {
+	defer self.wg.Done()
+
+	for {
+		conn, err := self.readers.AcceptUnix()
+		if err != nil {
+			return
+		}
+
+		if !self.track(conn) {
+			conn.Close()
+			return
+		}
+
+		self.wg.Add(1)
+		go self.serveReader(conn)
+	}
+}
+
+// serveReader answers the query sent by a single reader.
+func (self *Server) serveReader(conn *net.UnixConn) {
+	defer self.wg.Done()
+	defer self.untrack(conn)
+	defer conn.Close()
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return
+	}
+
+	q := parseQuery(string(buf[:n]))
+	backlog, s := self.subscribe(q)
+
+	for _, rec := range backlog {
+		if _, err := conn.Write(encodeRecord(rec)); err != nil {
+			self.unsubscribe(s)
+			return
+		}
+	}
+
+	if s == nil {
+		return
+	}
+
+	// Detect the reader going away while we are waiting for new records.
+	go func() {
+		conn.Read(buf)
+		self.unsubscribe(s)
+	}()
+
+	for rec := range s.records {
+		if _, err := conn.Write(encodeRecord(rec)); err != nil {
+			self.unsubscribe(s)
+			return
+		}
+	}
+}
+
+// subscribe returns all stored records selected by q, and, unless q is a
+// dump, registers a subscriber receiving all subsequent records.
+func (self *Server) subscribe(q query) ([]*record, *subscriber) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	var backlog []*record
+	for _, r := range self.rings {
+		for _, rec := range r.records {
+			if q.match(rec) {
+				backlog = append(backlog, rec)
+			}
+		}
+	}
+
+	sort.Slice(backlog, func(i, j int) bool { return backlog[i].seq < backlog[j].seq })
+
+	if q.tail > 0 && len(backlog) > q.tail {
+		backlog = backlog[len(backlog)-q.tail:]
+	}
+
+	if q.dump || self.closed {
+		return backlog, nil
+	}
+
+	s := &subscriber{q: q, records: make(chan *record, readerQueue)}
+	self.subscribers[s] = true
+	return backlog, s
+}
+
+func (self *Server) unsubscribe(s *subscriber) {
+	if s == nil {
+		return
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if self.subscribers[s] {
+		delete(self.subscribers, s)
+		close(s.records)
+	}
+}
+
+// encodeRecord frames rec as logger_entry_v4.
+func encodeRecord(rec *record) []byte {
+	e := &rec.entry
+	payload := 1 + len(e.Tag) + 1 + len(e.Message) + 1
+
+	b := make([]byte, entryV4Size, entryV4Size+payload)
+	binary.LittleEndian.PutUint16(b[0:], uint16(payload))
+	binary.LittleEndian.PutUint16(b[2:], entryV4Size)
+	binary.LittleEndian.PutUint32(b[4:], uint32(e.Pid))
+	binary.LittleEndian.PutUint32(b[8:], uint32(e.Tid))
+	binary.LittleEndian.PutUint32(b[12:], uint32(e.When.Seconds))
+	binary.LittleEndian.PutUint32(b[16:], uint32(e.When.Nanoseconds))
+	binary.LittleEndian.PutUint32(b[20:], uint32(rec.lid))
+	binary.LittleEndian.PutUint32(b[24:], rec.uid)
+
+	b = append(b, byte(e.Priority))
+	b = append(b, e.Tag...)
+	b = append(b, 0)
+	b = append(b, e.Message...)
+	return append(b, 0)
+}
+
+// serveControl accepts connections on the control socket.
+func (self *Server) serveControl() {
+	defer self.wg.Done()
+
+	for {
+		conn, err := self.control.AcceptUnix()
+		if err != nil {
+			return
+		}
+
+		if !self.track(conn) {
+			conn.Close()
+			return
+		}
+
+		self.wg.Add(1)
+		go self.serveCommands(conn)
+	}
+}
+
+// serveCommands answers NUL-terminated commands sent over conn.
+func (self *Server) serveCommands(conn *net.UnixConn) {
+	defer self.wg.Done()
+	defer self.untrack(conn)
+	defer conn.Close()
+
+	var pending []byte
+	buf := make([]byte, 256)
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		pending = append(pending, buf[:n]...)
+		for {
+			i := bytes.IndexByte(pending, 0)
+			if i < 0 {
+				break
+			}
+
+			response := self.command(string(pending[:i]))
+			pending = pending[i+1:]
+
+			if _, err := conn.Write(append([]byte(response), 0)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// command executes a single control command, returning logd's response.
+func (self *Server) command(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) < 2 {
+		return "Invalid argument"
+	}
+
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "Invalid argument"
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	r, ok := self.rings[alog.LogId(n)]
+	if !ok {
+		return "Invalid argument"
+	}
+
+	switch fields[0] {
+	case "clear":
+		r.clear()
+		return "success"
+	case "getLogSize":
+		return strconv.Itoa(r.capacity)
+	case "getLogSizeUsed":
+		return strconv.Itoa(r.used)
+	case "setLogSize":
+		if len(fields) < 3 {
+			return "Invalid argument"
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size <= 0 {
+			return "Invalid argument"
+		}
+
+		r.capacity = size
+		return "success"
+	}
+
+	return "Invalid argument"
+}
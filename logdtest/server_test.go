@@ -0,0 +1,208 @@
+package logdtest
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vosst/alog"
+)
+
+var testTag = alog.Tag("Test")
+
+func withServer(t *testing.T, f func(s *Server)) {
+	s, err := NewServer(Config{})
+	require.NoError(t, err)
+	defer s.Close()
+
+	f(s)
+}
+
+// waitForEntries waits until the log identified by id holds n entries.
+func waitForEntries(t *testing.T, s *Server, id alog.LogId, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Entries(id)) < n {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for entries")
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func dump(t *testing.T, s *Server, q alog.LogdQuery) []*alog.Entry {
+	q.Dump = true
+
+	reader, err := s.Logd.NewReader(q)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var entries []*alog.Entry
+	for {
+		reader.SetDeadline(time.Now().Add(time.Second))
+		entry, err := reader.ReadNext()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+func TestServerReceivesEntriesFromLogdWriter(t *testing.T) {
+	withServer(t, func(s *Server) {
+		w, err := s.Logd.NewWriter(alog.LogIdSystem)
+		require.NoError(t, err)
+		defer w.Close()
+
+		require.NoError(t, alog.W(w, testTag, "42"))
+		waitForEntries(t, s, alog.LogIdSystem, 1)
+
+		entry := s.Entries(alog.LogIdSystem)[0]
+		assert.Equal(t, int32(os.Getpid()), entry.Pid)
+		assert.Equal(t, alog.PriorityWarn, entry.Priority)
+		assert.Equal(t, testTag, entry.Tag)
+		assert.Equal(t, "42", entry.Message)
+	})
+}
+
+func TestLogdReaderDumpsSelectedLogs(t *testing.T) {
+	withServer(t, func(s *Server) {
+		s.Append(alog.LogIdMain, 1000, alog.Entry{Pid: 1, Priority: alog.PriorityInfo, Tag: testTag, Message: "main"})
+		s.Append(alog.LogIdRadio, 1001, alog.Entry{Pid: 2, Priority: alog.PriorityInfo, Tag: testTag, Message: "radio"})
+		s.Append(alog.LogIdSystem, 1002, alog.Entry{Pid: 1, Priority: alog.PriorityInfo, Tag: testTag, Message: "system"})
+
+		entries := dump(t, s, alog.LogdQuery{LogIds: []alog.LogId{alog.LogIdMain, alog.LogIdSystem}})
+		require.Len(t, entries, 2)
+		assert.Equal(t, "main", entries[0].Message)
+		assert.Equal(t, "system", entries[1].Message)
+		assert.Equal(t, alog.LogIdSystem, entries[1].Ext[alog.ExtLogId])
+		assert.Equal(t, uint32(1002), entries[1].Ext[alog.ExtUid])
+
+		assert.Len(t, dump(t, s, alog.LogdQuery{}), 3)
+		assert.Len(t, dump(t, s, alog.LogdQuery{Tail: 1}), 1)
+		assert.Len(t, dump(t, s, alog.LogdQuery{Pid: 1}), 2)
+	})
+}
+
+func TestLogdReaderStreamsNewEntries(t *testing.T) {
+	withServer(t, func(s *Server) {
+		s.Append(alog.LogIdMain, 0, alog.Entry{Priority: alog.PriorityInfo, Tag: testTag, Message: "old"})
+
+		reader, err := s.Logd.NewReader(alog.LogdQuery{LogIds: []alog.LogId{alog.LogIdMain}, Tail: 1})
+		require.NoError(t, err)
+		defer reader.Close()
+
+		reader.SetDeadline(time.Now().Add(time.Second))
+		entry, err := reader.ReadNext()
+		require.NoError(t, err)
+		assert.Equal(t, "old", entry.Message)
+
+		w, err := s.Logd.NewWriter(alog.LogIdMain)
+		require.NoError(t, err)
+		defer w.Close()
+
+		require.NoError(t, alog.E(w, testTag, "new"))
+
+		reader.SetDeadline(time.Now().Add(time.Second))
+		entry, err = reader.ReadNext()
+		require.NoError(t, err)
+		assert.Equal(t, "new", entry.Message)
+
+		reader.SetDeadline(time.Now().Add(50 * time.Millisecond))
+		_, err = reader.ReadNext()
+		assert.Equal(t, alog.ErrReadTimeout, err)
+	})
+}
+
+func TestLogdReaderReadsBatches(t *testing.T) {
+	withServer(t, func(s *Server) {
+		for _, m := range []string{"1", "2", "3", "4", "5"} {
+			s.Append(alog.LogIdMain, 0, alog.Entry{Priority: alog.PriorityInfo, Tag: testTag, Message: m})
+		}
+
+		entries := make([]alog.Entry, 3)
+
+		// The server's writes race with our reads, such that a single
+		// attempt might find one entry available at a time only.
+		batched := false
+		for attempt := 0; attempt < 10 && !batched; attempt++ {
+			reader, err := s.Logd.NewReader(alog.LogdQuery{LogIds: []alog.LogId{alog.LogIdMain}})
+			require.NoError(t, err)
+
+			reader.SetDeadline(time.Now().Add(time.Second))
+			n, err := reader.ReadBatch(entries[:1])
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+			assert.Equal(t, "1", entries[0].Message)
+
+			var messages []string
+			for len(messages) < 4 {
+				reader.SetDeadline(time.Now().Add(time.Second))
+				n, err = reader.ReadBatch(entries)
+				require.NoError(t, err)
+				require.True(t, n > 0 && n <= len(entries))
+				batched = batched || n > 1
+
+				for _, entry := range entries[:n] {
+					messages = append(messages, entry.Message)
+				}
+			}
+			assert.Equal(t, []string{"2", "3", "4", "5"}, messages)
+
+			reader.SetDeadline(time.Now().Add(50 * time.Millisecond))
+			_, err = reader.ReadBatch(entries)
+			assert.Equal(t, alog.ErrReadTimeout, err)
+
+			reader.Close()
+		}
+
+		assert.True(t, batched, "No batch held more than one entry")
+	})
+}
+
+func TestRingBufferDropsOldestEntries(t *testing.T) {
+	s, err := NewServer(Config{BufferSize: 100})
+	require.NoError(t, err)
+	defer s.Close()
+
+	for i := 0; i < 10; i++ {
+		s.Append(alog.LogIdMain, 0, alog.Entry{Tag: testTag, Message: "0123456789"})
+	}
+
+	assert.Len(t, s.Entries(alog.LogIdMain), 2)
+
+	used, err := s.Logd.LogSizeUsed(alog.LogIdMain)
+	require.NoError(t, err)
+	assert.True(t, used <= 100)
+}
+
+func TestControlCommands(t *testing.T) {
+	withServer(t, func(s *Server) {
+		s.Append(alog.LogIdMain, 0, alog.Entry{Tag: testTag, Message: "42"})
+
+		size, err := s.Logd.LogSize(alog.LogIdMain)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultBufferSize, size)
+
+		used, err := s.Logd.LogSizeUsed(alog.LogIdMain)
+		require.NoError(t, err)
+		assert.True(t, used > 0)
+
+		require.NoError(t, s.Logd.Clear(alog.LogIdMain))
+		assert.Empty(t, s.Entries(alog.LogIdMain))
+
+		assert.Error(t, s.Logd.Clear(alog.LogId(42)))
+	})
+}
+
+func TestCloseRemovesTemporaryDirectory(t *testing.T) {
+	s, err := NewServer(Config{})
+	require.NoError(t, err)
+
+	assert.True(t, s.Logd.Available())
+	require.NoError(t, s.Close())
+
+	_, err = os.Stat(s.Logd.Dir)
+	assert.True(t, os.IsNotExist(err))
+}
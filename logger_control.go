@@ -0,0 +1,76 @@
+package alog
+
+// #include <sys/ioctl.h>
+// #define __LOGGERIO 0xAE
+// #define LOGGER_GET_LOG_BUF_SIZE	_IO(__LOGGERIO, 1) /* size of log */
+// #define LOGGER_GET_LOG_LEN		_IO(__LOGGERIO, 2) /* used log len */
+// #define LOGGER_FLUSH_LOG		_IO(__LOGGERIO, 4) /* flush log */
+//
+// int GetLoggerBufSize(int fd)
+// {
+//     return ioctl(fd, LOGGER_GET_LOG_BUF_SIZE);
+// }
+//
+// int GetLoggerLen(int fd)
+// {
+//     return ioctl(fd, LOGGER_GET_LOG_LEN);
+// }
+//
+// int FlushLogger(int fd)
+// {
+//     return ioctl(fd, LOGGER_FLUSH_LOG);
+// }
+import "C"
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// loggerDevice returns the path of the kernel logger device for id.
+func loggerDevice(id LogId) string {
+	return filepath.Join("/dev", "alog", id.String())
+}
+
+// LoggerLogSize returns the capacity in bytes of the kernel log identified by id
+// and the number of bytes currently used.
+//
+// Returns an error if accessing the kernel log fails.
+func LoggerLogSize(id LogId) (int, int, error) {
+	f, err := os.Open(loggerDevice(id))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer f.Close()
+
+	size, err := C.GetLoggerBufSize(C.int(f.Fd()))
+	if size < 0 {
+		return 0, 0, err
+	}
+
+	used, err := C.GetLoggerLen(C.int(f.Fd()))
+	if used < 0 {
+		return 0, 0, err
+	}
+
+	return int(size), int(used), nil
+}
+
+// ClearLoggerLog discards all entries of the kernel log identified by id.
+//
+// Returns an error if accessing the kernel log fails.
+func ClearLoggerLog(id LogId) error {
+	f, err := os.OpenFile(loggerDevice(id), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if rc, err := C.FlushLogger(C.int(f.Fd())); rc < 0 {
+		return err
+	}
+
+	return nil
+}
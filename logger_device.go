@@ -0,0 +1,154 @@
+package alog
+
+// #include <sys/ioctl.h>
+// #define __LOGGERIO 0xAE
+// #define LOGGER_GET_VERSION		_IO(__LOGGERIO, 5) /* abi version */
+// #define LOGGER_SET_VERSION		_IO(__LOGGERIO, 6) /* abi version */
+//
+// int GetLoggerVersion(int fd)
+// {
+//     return ioctl(fd, LOGGER_GET_VERSION);
+// }
+//
+// int SetLoggerVersion(int fd, int version)
+// {
+//     return ioctl(fd, LOGGER_SET_VERSION, &version);
+// }
+import "C"
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/npat-efault/poller"
+)
+
+// A LoggerDevice abstracts access to one of the kernel logger's devices,
+// enabling LoggerReader and LoggerWriter to operate on implementations
+// other than the kernel's.
+type LoggerDevice interface {
+	// A LoggerDevice has to be closed explicitly.
+	io.Closer
+
+	// Read reads exactly one raw entry into b, consisting of a logger_entry
+	// header in the ABI version selected by SetVersion and the payload.
+	//
+	// Returns an error if b is too small to hold the next entry or once
+	// the deadline set by SetDeadline is exceeded.
+	Read(b []byte) (int, error)
+
+	// Write writes exactly one entry with payload b, consisting of
+	// priority, NUL-terminated tag and NUL-terminated message.
+	Write(b []byte) (int, error)
+
+	// SetDeadline adjusts the deadline such that all subsequent calls to
+	// Read will fail if they exceed t. If t lies in the past, Read returns
+	// an entry if one is immediately available and ErrReadTimeout otherwise.
+	SetDeadline(t time.Time) error
+
+	// Version returns the ABI version of entries returned by Read.
+	//
+	// Returns an error if the device does not support versioning, i.e., only
+	// supports ABI version 1.
+	Version() (int, error)
+
+	// SetVersion requests the ABI version of entries returned by Read.
+	SetVersion(version int) error
+}
+
+// A kernelLoggerDevice implements LoggerDevice for the kernel logger's devices.
+type kernelLoggerDevice struct {
+	fd       *poller.FD // The file we read entries from and write entries to
+	deadline time.Time  // The deadline for reading
+}
+
+// OpenLoggerDevice opens the kernel logger's device for the log identified
+// by id, for reading only or, if write is true, for reading and writing.
+//
+// Returns an error if opening the device fails.
+func OpenLoggerDevice(id LogId, write bool) (LoggerDevice, error) {
+	flags := poller.O_RO
+	if write {
+		flags = poller.O_RW
+	}
+
+	fd, err := poller.Open(loggerDevice(id), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kernelLoggerDevice{fd: fd}, nil
+}
+
+func (self *kernelLoggerDevice) Close() error {
+	return self.fd.Close()
+}
+
+// Read reads the next entry from the device. If the deadline has passed,
+// Read does not wait for the device to become readable but reads from
+// the non-blocking file descriptor directly.
+//
+// Returns ErrReadTimeout if the deadline is exceeded.
+func (self *kernelLoggerDevice) Read(b []byte) (int, error) {
+	if self.deadline.IsZero() || self.deadline.After(time.Now()) {
+		n, err := self.fd.Read(b)
+
+		var te interface{ Timeout() bool }
+		if errors.As(err, &te) && te.Timeout() {
+			return n, ErrReadTimeout
+		}
+		return n, err
+	}
+
+	self.fd.Lock()
+	defer self.fd.Unlock()
+
+	n, err := syscall.Read(self.fd.Sysfd(), b)
+	if err == syscall.EAGAIN {
+		return 0, ErrReadTimeout
+	} else if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (self *kernelLoggerDevice) Write(b []byte) (int, error) {
+	return self.fd.Write(b)
+}
+
+func (self *kernelLoggerDevice) SetDeadline(t time.Time) error {
+	self.deadline = t
+	return self.fd.SetReadDeadline(t)
+}
+
+// Version issues an ioctl to query the AOSP Logger wire format version.
+//
+// Returns an error if the ioctl fails, as it does for kernels only supporting version 1.
+func (self *kernelLoggerDevice) Version() (int, error) {
+	self.fd.Lock()
+	defer self.fd.Unlock()
+
+	version, err := C.GetLoggerVersion(C.int(self.fd.Sysfd()))
+	if version < 0 {
+		return 0, err
+	}
+
+	return int(version), nil
+}
+
+// SetVersion issues an ioctl to request AOSP Logger wire format version.
+//
+// Returns an error if the ioctl fails.
+func (self *kernelLoggerDevice) SetVersion(version int) error {
+	self.fd.Lock()
+	defer self.fd.Unlock()
+
+	if rc, err := C.SetLoggerVersion(C.int(self.fd.Sysfd()), C.int(version)); rc != 0 {
+		return err
+	}
+
+	return nil
+}
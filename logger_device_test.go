@@ -0,0 +1,43 @@
+package alog
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/npat-efault/poller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withPipeDevice hands a kernelLoggerDevice reading from the empty pipe to f.
+func withPipeDevice(t *testing.T, f func(dev *kernelLoggerDevice)) {
+	var fds [2]int
+	require.NoError(t, syscall.Pipe(fds[:]))
+	defer syscall.Close(fds[1])
+
+	require.NoError(t, syscall.SetNonblock(fds[0], true))
+	fd, err := poller.NewFD(fds[0])
+	require.NoError(t, err)
+
+	dev := &kernelLoggerDevice{fd: fd}
+	defer dev.Close()
+
+	f(dev)
+}
+
+func TestKernelLoggerDeviceTimesOutWaitingForEntries(t *testing.T) {
+	withPipeDevice(t, func(dev *kernelLoggerDevice) {
+		require.NoError(t, dev.SetDeadline(time.Now().Add(10*time.Millisecond)))
+		_, err := dev.Read(make([]byte, maxEntrySize))
+		assert.Equal(t, ErrReadTimeout, err)
+	})
+}
+
+func TestKernelLoggerDeviceTimesOutForPassedDeadline(t *testing.T) {
+	withPipeDevice(t, func(dev *kernelLoggerDevice) {
+		require.NoError(t, dev.SetDeadline(time.Now().Add(-time.Second)))
+		_, err := dev.Read(make([]byte, maxEntrySize))
+		assert.Equal(t, ErrReadTimeout, err)
+	})
+}
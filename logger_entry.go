@@ -0,0 +1,209 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	loggerEntryHeaderSize = 20 // Size of the v1 logger_entry header, common to all ABI versions
+)
+
+// appendLoggerEntry appends entry to b, framed as a v1 logger_entry with
+// its payload consisting of priority, NUL-terminated tag and NUL-terminated message.
+// The raw message of entry takes precedence over its message, if present.
+func appendLoggerEntry(b []byte, entry *Entry) []byte {
+	message := entry.Message
+	if entry.RawMessage != nil {
+		message = string(entry.RawMessage)
+	}
+
+	payload := 1 + len(entry.Tag) + 1 + len(message) + 1
+
+	var hdr [loggerEntryHeaderSize]byte
+	binary.LittleEndian.PutUint16(hdr[0:], uint16(payload))
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(entry.Pid))
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(entry.Tid))
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(entry.When.Seconds))
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(entry.When.Nanoseconds))
+
+	b = append(b, hdr[:]...)
+	b = append(b, byte(entry.Priority))
+	b = append(b, entry.Tag...)
+	b = append(b, 0)
+	b = append(b, message...)
+	return append(b, 0)
+}
+
+// parsePayload splits buf into priority, NUL-terminated tag and message,
+// placing the results in entry. The message ends at its NUL terminator or, if
+// the terminator is missing, e.g., due to truncation, at the end of buf. If
+// tags is not nil, the tag is interned in tags. If raw is true, the exact
+// message bytes up to the trailing terminator, including any embedded NULs,
+// are copied to entry.RawMessage, reusing its capacity.
+//
+// Returns ErrBadLength if buf is too short.
+// Returns ErrMissingTagTerminator if buf lacks the tag terminator.
+func parsePayload(buf []byte, entry *Entry, tags *TagTable, raw bool) error {
+	if len(buf) < 3 { // We need at least a priority, and two \0.
+		return ErrBadLength
+	}
+
+	tagEnd := bytes.IndexByte(buf[1:], 0)
+	if tagEnd < 0 {
+		return ErrMissingTagTerminator
+	}
+
+	payload := bytes.TrimSuffix(buf[tagEnd+2:], []byte{0})
+	message := payload
+	if i := bytes.IndexByte(message, 0); i >= 0 {
+		message = message[:i]
+	}
+
+	entry.Priority = Priority(buf[0])
+	entry.Tag = tags.Intern(buf[1 : tagEnd+1])
+	entry.Message = messageString(message)
+
+	if raw {
+		entry.RawMessage = append(entry.RawMessage[:0], payload...)
+	} else {
+		entry.RawMessage = nil
+	}
+
+	return nil
+}
+
+// messageString returns message without leading and trailing whitespace as a
+// string, replacing invalid UTF-8 sequences with the Unicode replacement character.
+func messageString(message []byte) string {
+	message = bytes.TrimSpace(message)
+	if !utf8.Valid(message) {
+		message = bytes.ToValidUTF8(message, []byte("\uFFFD"))
+	}
+
+	return string(message)
+}
+
+// loggerRecordSize inspects the first 4 bytes of a logger_entry and returns
+// the size of its header and of the complete record. A hdr_size of 0 denotes
+// the v1 ABI with its fixed header size.
+func loggerRecordSize(hdr []byte) (int, int) {
+	length := int(binary.LittleEndian.Uint16(hdr[0:]))
+	hdrSize := int(binary.LittleEndian.Uint16(hdr[2:]))
+	if hdrSize == 0 {
+		hdrSize = loggerEntryHeaderSize
+	}
+
+	return hdrSize, hdrSize + length
+}
+
+// parseLoggerRecord parses a complete logger_entry record into a new Entry.
+//
+// Returns a *ParseError if record is malformed or abiExtension fails.
+func parseLoggerRecord(record []byte, abiExtension LoggerAbiExtension) (*Entry, error) {
+	p := recordParser{abiExtension: abiExtension, id: LogIdUnknown}
+	entry := &Entry{}
+
+	if err := p.parse(record, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// A recordParser parses logger_entry records into caller-provided entries,
+// reusing its state across calls. Parsing a record without abiExtension
+// allocates the message only, and the tag if it is not found in tags.
+type recordParser struct {
+	abiExtension LoggerAbiExtension // ABI extension handler, may be nil
+	tags         *TagTable          // Table for interning tags, may be nil
+	raw          bool               // Whether to preserve the exact message bytes
+	id           LogId              // The log records are read from, reported in errors
+	offset       int64              // Offset of the next record
+	lenient      bool               // Whether readers skip malformed records
+	skipped      uint64             // Number of malformed records skipped
+	r            bytes.Reader       // Reader handed to abiExtension
+}
+
+// parse parses a complete logger_entry record into entry, overwriting all of
+// its fields and honouring the hdr_size field of v2 and later ABIs. If
+// abiExtension is not nil, it is handed exactly the header bytes following the
+// v1 header. Header bytes not consumed by abiExtension are skipped, such that
+// unknown vendor additions to the header do not end up in tag or message.
+//
+// Returns a *ParseError if record is malformed or abiExtension fails.
+func (self *recordParser) parse(record []byte, entry *Entry) error {
+	entry.Ext = nil
+
+	if len(record) < loggerEntryHeaderSize {
+		return self.fail(ErrShortHeader, nil, record, len(record), entry)
+	}
+
+	hdrSize, size := loggerRecordSize(record)
+	if hdrSize < loggerEntryHeaderSize {
+		return self.fail(ErrShortHeader, nil, record, len(record), entry)
+	} else if size > len(record) {
+		return self.fail(ErrBadLength, nil, record, len(record), entry)
+	}
+
+	entry.Pid = int32(binary.LittleEndian.Uint32(record[4:]))
+	entry.Tid = int32(binary.LittleEndian.Uint32(record[8:]))
+	entry.When = Timestamp{
+		Seconds:     int32(binary.LittleEndian.Uint32(record[12:])),
+		Nanoseconds: int32(binary.LittleEndian.Uint32(record[16:])),
+	}
+
+	if self.abiExtension != nil {
+		self.r.Reset(record[loggerEntryHeaderSize:hdrSize])
+		ext, err := self.abiExtension.Read(&self.r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("Log entry header of %d bytes is too short for ABI extension", hdrSize)
+		}
+
+		if err != nil {
+			return self.fail(ErrExtensionFailed, err, record, len(record), entry)
+		}
+		entry.Ext = ext
+	}
+
+	if err := parsePayload(record[hdrSize:size], entry, self.tags, self.raw); err != nil {
+		return self.fail(err, nil, record, len(record), entry)
+	}
+
+	self.offset += int64(len(record))
+	return nil
+}
+
+// fail returns a *ParseError of kind for record, which occupies size bytes
+// of the stream read by self. The log is taken from the partially parsed
+// entry if self does not know it.
+func (self *recordParser) fail(kind error, err error, record []byte, size int, entry *Entry) error {
+	id := self.id
+	if lid, ok := entry.LogId(); ok && id == LogIdUnknown {
+		id = lid
+	}
+
+	pe := &ParseError{Kind: kind, Err: err, LogId: id, Offset: self.offset, Raw: append([]byte(nil), record...)}
+	self.offset += int64(size)
+	return pe
+}
+
+// skip returns true if self is lenient and err is a *ParseError, counting
+// the malformed record as skipped.
+func (self *recordParser) skip(err error) bool {
+	if err == nil || !self.lenient {
+		return false
+	}
+
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		return false
+	}
+
+	self.skipped++
+	return true
+}
@@ -0,0 +1,136 @@
+package alog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePayloadSplitsTagAndMessage(t *testing.T) {
+	entry := &Entry{}
+	require.NoError(t, parsePayload([]byte("\x04Test\x0042\x00"), entry, nil, false))
+
+	assert.Equal(t, PriorityInfo, entry.Priority)
+	assert.Equal(t, testTag, entry.Tag)
+	assert.Equal(t, "42", entry.Message)
+}
+
+func TestParsePayloadToleratesMissingMessageTerminator(t *testing.T) {
+	entry := &Entry{}
+	require.NoError(t, parsePayload([]byte("\x04Test\x0042"), entry, nil, false))
+
+	assert.Equal(t, "42", entry.Message)
+}
+
+func TestParsePayloadRejectsInvalidPayloads(t *testing.T) {
+	assert.Error(t, parsePayload([]byte("\x04T"), &Entry{}, nil, false))
+	assert.Error(t, parsePayload([]byte("\x04Test"), &Entry{}, nil, false))
+}
+
+func TestParseLoggerRecordRoundTripsAppendLoggerEntry(t *testing.T) {
+	entry, err := parseLoggerRecord(appendLoggerEntry(nil, &testEntry), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, testEntry, *entry)
+}
+
+func TestParseLoggerRecordRejectsInconsistentLength(t *testing.T) {
+	record := appendLoggerEntry(nil, &testEntry)
+	record[0]++
+
+	_, err := parseLoggerRecord(record, nil)
+	assert.Error(t, err)
+}
+
+// withExtraHeader returns a record for entry with extra inserted between the
+// v1 header and the payload, and hdr_size adjusted accordingly.
+func withExtraHeader(entry *Entry, extra []byte) []byte {
+	record := appendLoggerEntry(nil, entry)
+
+	b := append([]byte{}, record[:loggerEntryHeaderSize]...)
+	b = append(b, extra...)
+	b = append(b, record[loggerEntryHeaderSize:]...)
+	b[2] = byte(loggerEntryHeaderSize + len(extra))
+
+	return b
+}
+
+func TestParseLoggerRecordSkipsUnknownHeaderBytes(t *testing.T) {
+	record := withExtraHeader(&testEntry, []byte{0xe8, 0x03, 0, 0, 0xde, 0xad, 0xbe, 0xef})
+
+	entry, err := parseLoggerRecord(record, LoggerAbiV2Extension{})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint32(1000), entry.Ext[ExtEuid])
+	assert.Equal(t, testEntry.Tag, entry.Tag)
+	assert.Equal(t, testEntry.Message, entry.Message)
+}
+
+func TestParseLoggerRecordSkipsHeaderWithoutExtension(t *testing.T) {
+	entry, err := parseLoggerRecord(withExtraHeader(&testEntry, []byte{1, 2, 3, 4}), nil)
+	require.NoError(t, err)
+
+	assert.Nil(t, entry.Ext)
+	assert.Equal(t, testEntry, *entry)
+}
+
+func TestParseLoggerRecordFailsIfHeaderIsTooShortForExtension(t *testing.T) {
+	_, err := parseLoggerRecord(withExtraHeader(&testEntry, []byte{1, 2}), LoggerAbiV2Extension{})
+	assert.Error(t, err)
+}
+
+func TestParseLoggerRecordRejectsInvalidHeaderSize(t *testing.T) {
+	record := appendLoggerEntry(nil, &testEntry)
+
+	record[2] = loggerEntryHeaderSize - 1
+	_, err := parseLoggerRecord(record, nil)
+	assert.Error(t, err)
+
+	record[2] = 0xff
+	_, err = parseLoggerRecord(record, nil)
+	assert.Error(t, err)
+}
+
+func TestParsePayloadPreservesRawMessage(t *testing.T) {
+	entry := &Entry{}
+	require.NoError(t, parsePayload([]byte("\x04Test\x00\tat main.go:42\n\x00"), entry, nil, true))
+
+	assert.Equal(t, []byte("\tat main.go:42\n"), entry.RawMessage)
+	assert.Equal(t, "at main.go:42", entry.Message)
+
+	require.NoError(t, parsePayload([]byte("\x04Test\x0042\x00"), entry, nil, false))
+	assert.Nil(t, entry.RawMessage)
+}
+
+func TestParsePayloadCutsOnlyMessageAtEmbeddedNul(t *testing.T) {
+	entry := &Entry{}
+	require.NoError(t, parsePayload([]byte("\x04Test\x0042\x00garbage\x00"), entry, nil, true))
+
+	assert.Equal(t, "42", entry.Message)
+	assert.Equal(t, []byte("42\x00garbage"), entry.RawMessage)
+
+	require.NoError(t, parsePayload([]byte("\x04Test\x0042\x00garbage"), entry, nil, true))
+	assert.Equal(t, "42", entry.Message)
+	assert.Equal(t, []byte("42\x00garbage"), entry.RawMessage)
+}
+
+func TestParsePayloadReplacesInvalidUtf8InMessage(t *testing.T) {
+	entry := &Entry{}
+	require.NoError(t, parsePayload([]byte("\x04Test\x004\xff2\x00"), entry, nil, true))
+
+	assert.Equal(t, "4�2", entry.Message)
+	assert.Equal(t, []byte("4\xff2"), entry.RawMessage)
+}
+
+func TestAppendLoggerEntryPrefersRawMessage(t *testing.T) {
+	entry := testEntry
+	entry.RawMessage = []byte("  first\nsecond\n")
+
+	parsed := &Entry{}
+	p := recordParser{raw: true}
+	require.NoError(t, p.parse(appendLoggerEntry(nil, &entry), parsed))
+
+	assert.Equal(t, entry.RawMessage, parsed.RawMessage)
+	assert.Equal(t, testEntry.Message, parsed.Message)
+}
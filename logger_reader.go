@@ -1,63 +1,26 @@
 package alog
 
-// #include <sys/ioctl.h>
-// #define __LOGGERIO 0xAE
-// #define LOGGER_SET_VERSION		_IO(__LOGGERIO, 6) /* abi version */
-//
-// int RequestLoggerAbiV2(int fd)
-// {
-//     static int version = 2;
-//     return ioctl(fd, LOGGER_SET_VERSION, &version);
-// }
-import "C"
-
 import (
-	"bytes"
 	"encoding/binary"
-	"errors"
 	"io"
-	"io/ioutil"
-	"path/filepath"
-	"strings"
-	"syscall"
+	"reflect"
 	"time"
-
-	"github.com/npat-efault/poller"
 )
 
 const (
 	maxEntrySize = 5 * 1024 // Max size of a log entry when reading
 )
 
-// wire bundles all the fields available on the wire.
-// Used for parsing a single entry received from the Android logging
-// facilities.
-type wire struct {
-	Len  uint16
-	_    uint16
-	Pid  int32
-	Tid  int32
-	Sec  int32
-	Nsec int32
-}
-
-// requestExtendedLoggerAbi issues an ioctl on fd to request AOSP Logger wire format v2.
-//
-// Returns an error if the ioctl on fd fails.
-func requestExtendedLoggerAbi(fd int) error {
-	if rc := C.RequestLoggerAbiV2(C.int(fd)); rc != 0 {
-		return syscall.Errno(rc)
-	}
-
-	return nil
-}
-
 // A LoggerAbiExtension models additions to the logger v1 wire format defined by
 // AOSP.
 type LoggerAbiExtension interface {
 	// Read allows implementations to unmarshal addition fields from a reader
 	// into the buffer returned by a single read call to Android's logger facilities.
 	Read(reader io.Reader) (map[string]interface{}, error)
+
+	// Schema declares the keys placed into the extension map by Read,
+	// together with the type of their values.
+	Schema() map[string]reflect.Type
 }
 
 // A LoggerAbiV2Extension implements LoggerAbiExtension, reading the
@@ -73,14 +36,20 @@ func (self LoggerAbiV2Extension) Read(reader io.Reader) (map[string]interface{},
 	if err := binary.Read(reader, binary.LittleEndian, &euid); err != nil {
 		return nil, err
 	}
-	return map[string]interface{}{"euid": euid}, nil
+	return map[string]interface{}{ExtEuid: euid}, nil
+}
+
+// Schema declares the euid field.
+func (self LoggerAbiV2Extension) Schema() map[string]reflect.Type {
+	return map[string]reflect.Type{ExtEuid: reflect.TypeOf(uint32(0))}
 }
 
 // A LoggerReader connects to pre-Lollipop kernel logging facilities.
 type LoggerReader struct {
-	abiExtension LoggerAbiExtension // ABI extension handler
-	f            *poller.FD         // The file we read entries from
-	buf          []byte             // Buffer for reading raw bytes from f
+	parser   recordParser // Parses raw entries, honouring the ABI extension
+	f        LoggerDevice // The device we read entries from
+	buf      []byte       // Buffer for reading raw bytes from f
+	deadline time.Time    // The deadline for reading set by SetDeadline
 }
 
 // NewLoggerReader returns a new LoggerReader reading from the log stream
@@ -89,22 +58,34 @@ type LoggerReader struct {
 //
 // Returns an error if accessing the underlying Android log facilities fails.
 func NewLoggerReader(id LogId, abiExtension LoggerAbiExtension) (*LoggerReader, error) {
-	fn := filepath.Join("/dev", "alog", id.String())
+	dev, err := OpenLoggerDevice(id, false)
+	if err != nil {
+		return nil, err
+	}
 
-	p, err := poller.Open(fn, poller.O_RO)
+	lr, err := NewLoggerReaderForDevice(dev, abiExtension)
 	if err != nil {
+		dev.Close()
 		return nil, err
 	}
 
-	p.Lock()
-	defer p.Unlock()
+	lr.parser.id = id
+	return lr, nil
+}
+
+// NewLoggerReaderForDevice returns a new LoggerReader reading from dev. If
+// abiExtension is not nil, ABI version 2 is requested from dev and abiExtension
+// is used to parse additional fields from the entries read from dev.
+//
+// Returns an error if requesting ABI version 2 from dev fails.
+func NewLoggerReaderForDevice(dev LoggerDevice, abiExtension LoggerAbiExtension) (*LoggerReader, error) {
 	if abiExtension != nil {
-		if err = requestExtendedLoggerAbi(p.Sysfd()); err != nil {
+		if err := dev.SetVersion(2); err != nil {
 			return nil, err
 		}
 	}
 
-	return &LoggerReader{abiExtension: abiExtension, f: p, buf: make([]byte, maxEntrySize, maxEntrySize)}, nil
+	return &LoggerReader{parser: recordParser{abiExtension: abiExtension, id: LogIdUnknown}, f: dev, buf: make([]byte, maxEntrySize, maxEntrySize)}, nil
 }
 
 // Close() closes the underlying connection to the Android logger facilities.
@@ -119,52 +100,106 @@ func (self *LoggerReader) Close() error {
 // Returns an error if an issue arises in talking to the underlying
 // Android log facilities.
 func (self *LoggerReader) SetDeadline(t time.Time) error {
-	return self.f.SetReadDeadline(t)
+	if err := self.f.SetDeadline(t); err != nil {
+		return err
+	}
+
+	self.deadline = t
+	return nil
+}
+
+// SetTagTable makes self intern the tags of all entries read in tags.
+// Passing nil disables interning.
+func (self *LoggerReader) SetTagTable(tags *TagTable) {
+	self.parser.tags = tags
+}
+
+// SetRawMessages makes self preserve the exact message bytes of all entries
+// read in Entry.RawMessage, including leading and trailing whitespace and
+// invalid UTF-8 sequences. Entry.Message remains available as a trimmed view.
+func (self *LoggerReader) SetRawMessages(raw bool) {
+	self.parser.raw = raw
+}
+
+// SetLenient makes self skip malformed entries instead of returning a
+// *ParseError, counting them in Skipped.
+func (self *LoggerReader) SetLenient(lenient bool) {
+	self.parser.lenient = lenient
+}
+
+// Skipped returns the number of malformed entries skipped by self in lenient mode.
+func (self *LoggerReader) Skipped() uint64 {
+	return self.parser.skipped
 }
 
 // ReadNext reads the next entry from a LaggerReader. Extension fields (if any)
-// are placed into the Ext field of Entry.
+// are placed into the Ext field of Entry. The ABI extension is handed exactly the
+// header bytes announced by the hdr_size field of the entry beyond the v1 header.
+// Header bytes not consumed by the extension are skipped.
 //
 // Returns an error if reading from the underlying Android facilities fails,
 // specifically if the read operation times out.
+// Returns a *ParseError if the entry is malformed or its header is too short
+// for the ABI extension, unless self is lenient.
 func (self *LoggerReader) ReadNext() (*Entry, error) {
-	n, err := self.f.Read(self.buf)
-	if err != nil {
+	entry := &Entry{}
+	if err := self.ReadInto(entry); err != nil {
 		return nil, err
 	}
 
-	reader := bytes.NewReader(self.buf[:n])
+	return entry, nil
+}
 
-	w := wire{}
+// ReadInto is like ReadNext but reads the next entry into entry, overwriting
+// all of its fields. Reusing entry across calls and interning tags via
+// SetTagTable leaves the message as the only allocation per entry read without
+// ABI extension.
+//
+// Returns the same errors as ReadNext.
+func (self *LoggerReader) ReadInto(entry *Entry) error {
+	for {
+		n, err := self.f.Read(self.buf)
+		if err != nil {
+			return err
+		}
 
-	if err = binary.Read(reader, binary.LittleEndian, &w); err != nil {
-		return nil, err
+		if err := self.parser.parse(self.buf[:n], entry); !self.parser.skip(err) {
+			return err
+		}
 	}
+}
 
-	var ext map[string]interface{}
+// ReadBatch reads all immediately available entries into entries, up to
+// len(entries), and returns the number of entries read. ReadBatch waits for
+// the first entry until the deadline set by SetDeadline expires, and drains
+// the device without waiting from there on. Entries are overwritten as with
+// ReadInto.
+//
+// Returns the same errors as ReadNext for the first entry. Returns the
+// number of entries read so far together with the error if reading any
+// subsequent entry fails.
+func (self *LoggerReader) ReadBatch(entries []Entry) (int, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
 
-	if self.abiExtension != nil {
-		ext, err = self.abiExtension.Read(reader)
-		if err != nil {
-			return nil, err
-		}
+	if err := self.ReadInto(&entries[0]); err != nil {
+		return 0, err
 	}
 
-	if buf, err := ioutil.ReadAll(reader); err != nil {
-		return nil, err
-	} else if len(buf) > 3 { // We need at least a priority, and two \0.
-		tagEnd := bytes.IndexAny(buf[1:], "\x00")
-
-		return &Entry{
-			Pid:      w.Pid,
-			Tid:      w.Tid,
-			When:     Timestamp{Seconds: w.Sec, Nanoseconds: w.Nsec},
-			Priority: Priority(buf[0]),
-			Tag:      Tag(buf[1 : tagEnd+1]),
-			Message:  strings.TrimSpace(string(buf[tagEnd+1 : w.Len-1])),
-			Ext:      ext,
-		}, nil
-	} else {
-		return nil, errors.New("Invalid log entry")
+	if err := self.f.SetDeadline(time.Unix(0, 0)); err != nil {
+		return 1, err
+	}
+
+	n := 1
+	for ; n < len(entries); n++ {
+		if err := self.ReadInto(&entries[n]); err == ErrReadTimeout {
+			break
+		} else if err != nil {
+			self.f.SetDeadline(self.deadline)
+			return n, err
+		}
 	}
+
+	return n, self.f.SetDeadline(self.deadline)
 }
@@ -76,6 +76,127 @@ func TestLoggerReaderCallsNonNilAbiExtension(t *testing.T) {
 	mae.AssertNumberOfCalls(t, "Read", 1)
 }
 
+func TestLoggerReaderReadsAbiV1EntriesFromDevice(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+	fl.Append(LogIdMain, testEntry)
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), nil)
+	require.NoError(t, err)
+
+	defer lr.Close()
+
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry, *entry)
+}
+
+func TestLoggerReaderReadsAbiV2EntriesFromDevice(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, Euid: 1000})
+	fl.Append(LogIdMain, testEntry)
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), LoggerAbiV2Extension{})
+	require.NoError(t, err)
+
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Message, entry.Message)
+	assert.Equal(t, uint32(1000), entry.Ext[ExtEuid])
+}
+
+func TestLoggerReaderReadsVendorEntriesFromDevice(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, Euid: 1000, VendorHeader: []byte{0x10, 0x0e, 0, 0}})
+	fl.Append(LogIdMain, testEntry)
+
+	cext := ChainedLoggerAbiExtension{
+		Extensions: []LoggerAbiExtension{&quirk.MeizuMx4LoggerAbiExtension{}, &LoggerAbiV2Extension{}},
+	}
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), cext)
+	require.NoError(t, err)
+
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Tag, entry.Tag)
+	assert.Equal(t, testEntry.Message, entry.Message)
+	assert.Equal(t, uint32(1000), entry.Ext[ExtEuid])
+	assert.Equal(t, int32(3600), entry.Ext[ExtTimeZone])
+}
+
+func TestLoggerReaderDegradesGracefullyForUnknownVendorHeader(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2, VendorHeader: []byte{1, 2, 3, 4, 5, 6, 7, 8}})
+	fl.Append(LogIdMain, testEntry)
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), LoggerAbiV2Extension{})
+	require.NoError(t, err)
+
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Tag, entry.Tag)
+	assert.Equal(t, testEntry.Message, entry.Message)
+}
+
+func TestLoggerReaderFailsIfDeviceLacksAbiV2(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+
+	_, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), LoggerAbiV2Extension{})
+	assert.Error(t, err)
+}
+
+func TestLoggerReaderCallsNonNilAbiExtensionForDevice(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{MaxVersion: 2})
+	fl.Append(LogIdMain, testEntry)
+
+	mae := &MockLoggerAbiExtension{}
+	mae.On("Read", mock.Anything).Return(make(map[string]interface{}), nil)
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), mae)
+	require.NoError(t, err)
+
+	_, err = lr.ReadNext()
+	assert.NoError(t, err)
+
+	mae.AssertNumberOfCalls(t, "Read", 1)
+}
+
+func TestLoggerReadersHaveIndependentCursors(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+	fl.Append(LogIdRadio, testEntry)
+
+	lr1, err := NewLoggerReaderForDevice(fl.Open(LogIdRadio), nil)
+	require.NoError(t, err)
+
+	lr2, err := NewLoggerReaderForDevice(fl.Open(LogIdRadio), nil)
+	require.NoError(t, err)
+
+	_, err = lr1.ReadNext()
+	require.NoError(t, err)
+
+	_, err = lr2.ReadNext()
+	require.NoError(t, err)
+
+	lr1.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err = lr1.ReadNext()
+	assert.Equal(t, ErrReadTimeout, err)
+}
+
+func TestLoggerReaderSkipsOverwrittenEntries(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{Capacity: 200})
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		fl.Append(LogIdMain, Entry{Priority: PriorityInfo, Tag: testTag, Message: fmt.Sprintf("%02d", i)})
+	}
+
+	var messages []string
+	lr.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	for entry, err := lr.ReadNext(); err == nil; entry, err = lr.ReadNext() {
+		messages = append(messages, entry.Message)
+	}
+
+	assert.Equal(t, []string{"04", "05", "06", "07", "08", "09"}, messages)
+}
+
 func ExampleLoggerReader() {
 	lr, err := NewLoggerReader(LogIdMain, nil)
 	if err != nil {
@@ -91,3 +212,154 @@ func ExampleLoggerReader() {
 		fmt.Printf("%s/%s(%d): %s\n", le.Priority, le.Tag, le.Pid, le.Message)
 	}
 }
+
+// A replayLoggerDevice returns the same record on every read, isolating
+// the cost of parsing from the cost of emulating the kernel logger.
+type replayLoggerDevice struct {
+	record []byte
+}
+
+func (self *replayLoggerDevice) Close() error                  { return nil }
+func (self *replayLoggerDevice) Write(b []byte) (int, error)   { return len(b), nil }
+func (self *replayLoggerDevice) SetDeadline(t time.Time) error { return nil }
+func (self *replayLoggerDevice) Version() (int, error)         { return 2, nil }
+func (self *replayLoggerDevice) SetVersion(version int) error  { return nil }
+func (self *replayLoggerDevice) Read(b []byte) (int, error)    { return copy(b, self.record), nil }
+
+func TestLoggerReaderReadIntoOverwritesEntry(t *testing.T) {
+	lr, err := NewLoggerReaderForDevice(&replayLoggerDevice{record: appendLoggerEntry(nil, &testEntry)}, nil)
+	require.NoError(t, err)
+
+	entry := &Entry{Tag: "stale", Ext: map[string]interface{}{ExtEuid: uint32(0)}}
+	require.NoError(t, lr.ReadInto(entry))
+	assert.Equal(t, testEntry, *entry)
+}
+
+func TestLoggerReaderInternsTags(t *testing.T) {
+	lr, err := NewLoggerReaderForDevice(&replayLoggerDevice{record: appendLoggerEntry(nil, &testEntry)}, nil)
+	require.NoError(t, err)
+
+	tags := NewTagTable(0)
+	lr.SetTagTable(tags)
+
+	entry := &Entry{}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, lr.ReadInto(entry))
+		assert.Equal(t, testEntry.Tag, entry.Tag)
+	}
+	assert.Equal(t, 1, tags.Len())
+}
+
+func TestLoggerReaderReadIntoAllocatesMessageOnly(t *testing.T) {
+	lr, err := NewLoggerReaderForDevice(&replayLoggerDevice{record: appendLoggerEntry(nil, &testEntry)}, nil)
+	require.NoError(t, err)
+
+	lr.SetTagTable(NewTagTable(0))
+
+	entry := &Entry{}
+	allocs := testing.AllocsPerRun(100, func() {
+		lr.ReadInto(entry)
+	})
+	assert.Equal(t, float64(1), allocs)
+}
+
+func benchmarkLoggerReader(b *testing.B, abiExtension LoggerAbiExtension, readInto bool, tags *TagTable) {
+	record := appendLoggerEntry(nil, &testEntry)
+	if abiExtension != nil {
+		record = withExtraHeader(&testEntry, []byte{0xe8, 0x03, 0, 0})
+	}
+
+	lr, err := NewLoggerReaderForDevice(&replayLoggerDevice{record: record}, abiExtension)
+	require.NoError(b, err)
+
+	lr.SetTagTable(tags)
+
+	entry := &Entry{}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(record)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if readInto {
+			err = lr.ReadInto(entry)
+		} else {
+			entry, err = lr.ReadNext()
+		}
+
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoggerReaderReadNext(b *testing.B) {
+	benchmarkLoggerReader(b, nil, false, nil)
+}
+
+func BenchmarkLoggerReaderReadInto(b *testing.B) {
+	benchmarkLoggerReader(b, nil, true, nil)
+}
+
+func BenchmarkLoggerReaderReadIntoInterned(b *testing.B) {
+	benchmarkLoggerReader(b, nil, true, NewTagTable(0))
+}
+
+func BenchmarkLoggerReaderReadNextAbiV2(b *testing.B) {
+	benchmarkLoggerReader(b, LoggerAbiV2Extension{}, false, nil)
+}
+
+func BenchmarkLoggerReaderReadIntoAbiV2Interned(b *testing.B) {
+	benchmarkLoggerReader(b, LoggerAbiV2Extension{}, true, NewTagTable(0))
+}
+
+func TestLoggerReaderReadsBatches(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+	for _, m := range []string{"1", "2", "3", "4", "5"} {
+		fl.Append(LogIdMain, Entry{Priority: PriorityInfo, Tag: testTag, Message: m})
+	}
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), nil)
+	require.NoError(t, err)
+
+	entries := make([]Entry, 3)
+
+	n, err := lr.ReadBatch(entries)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	assert.Equal(t, "3", entries[2].Message)
+
+	n, err = lr.ReadBatch(entries)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	assert.Equal(t, "5", entries[1].Message)
+
+	lr.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err = lr.ReadBatch(entries)
+	assert.Equal(t, ErrReadTimeout, err)
+
+	n, err = lr.ReadBatch(nil)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestLoggerReaderReadBatchRestoresDeadline(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+	fl.Append(LogIdMain, testEntry)
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), nil)
+	require.NoError(t, err)
+
+	n, err := lr.ReadBatch(make([]Entry, 2))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		fl.Append(LogIdMain, testEntry)
+	}()
+
+	// Without a deadline, the next batch waits for the entry written above.
+	n, err = lr.ReadBatch(make([]Entry, 2))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -1,16 +1,10 @@
 package alog
 
-import (
-	"os"
-	"path/filepath"
-	"time"
-
-	"github.com/tedb/vectorio"
-)
+import "time"
 
 // A LoggerWriter implements Writer, sending log entries to Android's kernel logger.
 type LoggerWriter struct {
-	f *os.File // Open File representing our connection to Android's kernel logger.
+	f LoggerDevice // Our connection to Android's kernel logger.
 }
 
 // NewLoggerWriter opens a connection to Android's kernel logger for id,
@@ -18,14 +12,17 @@ type LoggerWriter struct {
 //
 // Returns an error if connection to the Android kernel logger with id fails.
 func NewLoggerWriter(id LogId) (*LoggerWriter, error) {
-	fn := filepath.Join("/dev", "alog", id.String())
-
-	f, err := os.OpenFile(fn, os.O_RDWR, 0666)
+	dev, err := OpenLoggerDevice(id, true)
 	if err != nil {
 		return nil, err
 	}
 
-	return &LoggerWriter{f: f}, nil
+	return NewLoggerWriterForDevice(dev), nil
+}
+
+// NewLoggerWriterForDevice returns a LoggerWriter sending log entries to dev.
+func NewLoggerWriterForDevice(dev LoggerDevice) *LoggerWriter {
+	return &LoggerWriter{f: dev}
 }
 
 // Close shuts down the connection to Android's kernel logger.
@@ -44,17 +41,16 @@ func (self *LoggerWriter) SetDeadline(t time.Time) error {
 //
 // Returns an error if writing to the kernel logger fails.
 func (self *LoggerWriter) Write(prio Priority, tag Tag, message string) error {
-	iov := [][]byte{
-		[]byte{byte(prio)},
-		[]byte(tag),
-		[]byte(message),
-	}
+	b := make([]byte, 0, 1+len(tag)+1+len(message)+1)
+	b = append(b, byte(prio))
 
 	// Both the tag and the message need to be null-terminated
-	iov[1] = append(iov[1], '\x00')
-	iov[2] = append(iov[2], '\x00')
+	b = append(b, tag...)
+	b = append(b, '\x00')
+	b = append(b, message...)
+	b = append(b, '\x00')
 
-	_, err := vectorio.Writev(self.f, iov)
+	_, err := self.f.Write(b)
 	return err
 }
 
@@ -1,10 +1,13 @@
 package alog
 
 import (
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var testTag = Tag("Test")
@@ -42,15 +45,7 @@ func testLoggerWriterWorks(logId LogId, t *testing.T) {
 
 	assert.Equal(t, testTag, entry.Tag)
 	assert.Equal(t, PriorityDebug, entry.Priority)
-	// TODO(tvoss): Figure out why this fails with:
-	// --- FAIL: TestLoggerWriteWorks (1.54 seconds)
-	//	logger_writer_test.go:37: &{Pid:21471 Tid:21476 When:{Seconds:1446319710 Nanoseconds:66931758} Priority:D Tag:Test Message:42 Ext:map[]}
-	// 	logger_writer_test.go:38: 42
-	// 	Error Trace:    logger_writer_test.go:41
-	//			logger_writer_test.go:45
-	//	Error:		Not equal: "42" (expected)
-	//			!= "\x0042" (astual)
-	// assert.Equal(t, "42", entry.Message)
+	assert.Equal(t, "42", entry.Message)
 }
 
 func TestLoggerWriteWorks(t *testing.T) {
@@ -106,6 +101,39 @@ func TestLoggerWriterConvenienceWorks(t *testing.T) {
 	assert.Equal(t, PriorityError, entry.Priority)
 }
 
+func TestLoggerWriterWritesToDevice(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdEvents), nil)
+	require.NoError(t, err)
+
+	lw := NewLoggerWriterForDevice(fl.Open(LogIdEvents))
+	require.NoError(t, lw.W(testTag, "42"))
+
+	lr.SetDeadline(time.Now().Add(500 * time.Millisecond))
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(os.Getpid()), entry.Pid)
+	assert.Equal(t, PriorityWarn, entry.Priority)
+	assert.Equal(t, testTag, entry.Tag)
+	assert.Equal(t, "42", entry.Message)
+}
+
+func TestLoggerWriterMessagesAreTruncatedByDevice(t *testing.T) {
+	fl := newFakeLogger(fakeLoggerConfig{})
+
+	lw := NewLoggerWriterForDevice(fl.Open(LogIdMain))
+	require.NoError(t, lw.I(testTag, strings.Repeat("x", 2*fakeLoggerMaxPayload)))
+
+	lr, err := NewLoggerReaderForDevice(fl.Open(LogIdMain), nil)
+	require.NoError(t, err)
+
+	entry, err := lr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, fakeLoggerMaxPayload-1-len(testTag)-1, len(entry.Message))
+}
+
 func ExampleLoggerWriter() {
 	writer, err := NewLoggerWriter(LogIdMain)
 	if err != nil {
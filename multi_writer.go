@@ -0,0 +1,82 @@
+package alog
+
+import (
+	"errors"
+	"time"
+)
+
+// A Sink pairs a Writer with the minimum priority of the log messages it receives.
+type Sink struct {
+	Writer      Writer   // The Writer log messages are sent to
+	MinPriority Priority // Messages with a lower priority are not sent to Writer
+}
+
+// A MultiWriter implements Writer, duplicating log messages to all of its Sinks,
+// similar to io.MultiWriter.
+//
+// By default, Write stops at the first Sink that fails and returns its error. If
+// ContinueOnError is true, all remaining Sinks are still written to and the errors
+// of all failing Sinks are combined with errors.Join.
+type MultiWriter struct {
+	Sinks           []Sink // All Sinks managed by a MultiWriter
+	ContinueOnError bool   // Whether to keep on writing to remaining Sinks if one fails
+}
+
+// NewMultiWriter returns a MultiWriter sending all log messages to writers.
+func NewMultiWriter(writers ...Writer) *MultiWriter {
+	sinks := make([]Sink, 0, len(writers))
+	for _, w := range writers {
+		sinks = append(sinks, Sink{Writer: w})
+	}
+
+	return &MultiWriter{Sinks: sinks}
+}
+
+// Close closes the Writers of all Sinks.
+//
+// Returns the errors of all failing Writers, combined with errors.Join.
+func (self *MultiWriter) Close() error {
+	var errs []error
+	for _, s := range self.Sinks {
+		if err := s.Writer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetDeadline forwards t to the Writers of all Sinks.
+//
+// Returns the errors of all failing Writers, combined with errors.Join.
+func (self *MultiWriter) SetDeadline(t time.Time) error {
+	var errs []error
+	for _, s := range self.Sinks {
+		if err := s.Writer.SetDeadline(t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Write logs message with prio and tag to all Sinks accepting prio.
+//
+// Returns an error if writing to any of the Sinks fails.
+func (self *MultiWriter) Write(prio Priority, tag Tag, message string) error {
+	var errs []error
+	for _, s := range self.Sinks {
+		if prio < s.MinPriority {
+			continue
+		}
+
+		if err := s.Writer.Write(prio, tag, message); err != nil {
+			if !self.ContinueOnError {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
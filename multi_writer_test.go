@@ -0,0 +1,94 @@
+package alog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiWriterWritesToAllWriters(t *testing.T) {
+	mw1 := &MockWriter{}
+	mw2 := &MockWriter{}
+
+	mw1.On("Write", PriorityDebug, testTag, "42").Return(nil)
+	mw2.On("Write", PriorityDebug, testTag, "42").Return(nil)
+
+	assert.NoError(t, D(NewMultiWriter(mw1, mw2), testTag, "42"))
+
+	mw1.AssertExpectations(t)
+	mw2.AssertExpectations(t)
+}
+
+func TestMultiWriterHonoursMinPriority(t *testing.T) {
+	all := &MockWriter{}
+	warn := &MockWriter{}
+
+	all.On("Write", PriorityInfo, testTag, "42").Return(nil)
+	all.On("Write", PriorityError, testTag, "42").Return(nil)
+	warn.On("Write", PriorityError, testTag, "42").Return(nil)
+
+	w := &MultiWriter{Sinks: []Sink{{Writer: all}, {Writer: warn, MinPriority: PriorityWarn}}}
+
+	assert.NoError(t, I(w, testTag, "42"))
+	assert.NoError(t, E(w, testTag, "42"))
+
+	all.AssertNumberOfCalls(t, "Write", 2)
+	warn.AssertNumberOfCalls(t, "Write", 1)
+}
+
+func TestMultiWriterStopsAtFirstError(t *testing.T) {
+	errFirst := errors.New("first")
+
+	mw1 := &MockWriter{}
+	mw2 := &MockWriter{}
+
+	mw1.On("Write", PriorityDebug, testTag, "42").Return(errFirst)
+
+	assert.Equal(t, errFirst, D(NewMultiWriter(mw1, mw2), testTag, "42"))
+	mw2.AssertNumberOfCalls(t, "Write", 0)
+}
+
+func TestMultiWriterContinuesOnErrorIfRequested(t *testing.T) {
+	errFirst := errors.New("first")
+	errThird := errors.New("third")
+
+	mw1 := &MockWriter{}
+	mw2 := &MockWriter{}
+	mw3 := &MockWriter{}
+
+	mw1.On("Write", PriorityDebug, testTag, "42").Return(errFirst)
+	mw2.On("Write", PriorityDebug, testTag, "42").Return(nil)
+	mw3.On("Write", PriorityDebug, testTag, "42").Return(errThird)
+
+	w := NewMultiWriter(mw1, mw2, mw3)
+	w.ContinueOnError = true
+
+	err := D(w, testTag, "42")
+	assert.True(t, errors.Is(err, errFirst))
+	assert.True(t, errors.Is(err, errThird))
+
+	mw2.AssertExpectations(t)
+}
+
+func TestMultiWriterClosesAndSetsDeadlineOnAllWriters(t *testing.T) {
+	errClose := errors.New("close")
+	deadline := time.Now()
+
+	mw1 := &MockWriter{}
+	mw2 := &MockWriter{}
+
+	mw1.On("Close").Return(errClose)
+	mw2.On("Close").Return(nil)
+	mw1.On("SetDeadline", deadline).Return(nil)
+	mw2.On("SetDeadline", deadline).Return(nil)
+
+	w := NewMultiWriter(mw1, mw2)
+
+	assert.NoError(t, w.SetDeadline(deadline))
+	assert.True(t, errors.Is(w.Close(), errClose))
+
+	mw1.AssertExpectations(t)
+	mw2.AssertExpectations(t)
+}
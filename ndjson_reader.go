@@ -0,0 +1,78 @@
+package alog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// maxNDJSONLine bounds the length of a single line read by NDJSONReader.
+const maxNDJSONLine = 1024 * 1024
+
+// An NDJSONReader implements Reader, decoding Entries from newline-delimited
+// JSON as written by NDJSONWriter. Empty lines are skipped.
+type NDJSONReader struct {
+	r      io.Reader               // The JSON we decode entries from
+	s      *bufio.Scanner          // Splits the input into lines
+	line   int                     // Number of the current line
+	schema map[string]reflect.Type // Types of extension values beyond ExtTypes
+}
+
+// NewNDJSONReader returns an NDJSONReader decoding the lines read from r.
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), maxNDJSONLine)
+	return &NDJSONReader{r: r, s: s}
+}
+
+// Close closes the underlying reader if it implements io.Closer.
+func (self *NDJSONReader) Close() error {
+	if c, ok := self.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// SetDeadline is a noop for NDJSONReader.
+func (self *NDJSONReader) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetSchema makes self restore extension values to the types given by
+// schema, e.g., as returned by LoggerAbiExtension.Schema, in addition to ExtTypes.
+func (self *NDJSONReader) SetSchema(schema map[string]reflect.Type) {
+	self.schema = schema
+}
+
+// ReadNext decodes the next entry.
+//
+// Returns io.EOF at the end of the input.
+// Returns an error naming the line if decoding fails.
+// Returns an error if reading fails.
+func (self *NDJSONReader) ReadNext() (*Entry, error) {
+	for self.s.Scan() {
+		self.line++
+
+		b := bytes.TrimSpace(self.s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		entry := &Entry{}
+		if err := unmarshalEntry(b, entry, self.schema); err != nil {
+			return nil, fmt.Errorf("Failed to decode entry in line %d: %w", self.line, err)
+		}
+
+		return entry, nil
+	}
+
+	if err := self.s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
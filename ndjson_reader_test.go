@@ -0,0 +1,45 @@
+package alog
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONRoundTripsEntries(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewNDJSONWriter(buf)
+
+	n, err := CopyEntries(w, &sliceReader{entries: []*Entry{&jsonTestEntry, &testEntry}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+
+	r := NewNDJSONReader(buf)
+
+	entry, err := r.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, jsonTestEntry, *entry)
+
+	entry, err = r.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry, *entry)
+
+	_, err = r.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestNDJSONReaderNamesLineOfMalformedEntries(t *testing.T) {
+	r := NewNDJSONReader(strings.NewReader("{\"tag\":\"Test\"}\n\n{\"pid\":\"x\"}\n"))
+
+	_, err := r.ReadNext()
+	require.NoError(t, err)
+
+	_, err = r.ReadNext()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+}
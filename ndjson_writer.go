@@ -0,0 +1,41 @@
+package alog
+
+import (
+	"io"
+	"sync"
+)
+
+// An NDJSONWriter encodes Entries to an io.Writer as newline-delimited JSON,
+// one object per line as produced by Entry.MarshalJSON. It is safe for
+// concurrent use.
+type NDJSONWriter struct {
+	m sync.Mutex // Serializes writes to w
+	w io.Writer  // The destination for encoded entries
+}
+
+// NewNDJSONWriter returns an NDJSONWriter sending entries to w. Closing the
+// NDJSONWriter does not close w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// Close is a noop for NDJSONWriter.
+func (self *NDJSONWriter) Close() error {
+	return nil
+}
+
+// WriteEntry encodes entry as a single line.
+//
+// Returns an error if encoding entry or writing to the underlying io.Writer fails.
+func (self *NDJSONWriter) WriteEntry(entry *Entry) error {
+	b, err := entry.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	_, err = self.w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,45 @@
+package alog
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of ParseError, to be tested for with errors.Is.
+var (
+	ErrShortHeader          = errors.New("Log entry header is too short")
+	ErrBadLength            = errors.New("Log entry length does not match the record")
+	ErrMissingTagTerminator = errors.New("Log entry tag lacks its NUL terminator")
+	ErrExtensionFailed      = errors.New("ABI extension failed to read the log entry header")
+	ErrMalformedHeader      = errors.New("Log entry header is malformed")
+)
+
+// A ParseError describes a raw log record that could not be parsed.
+// errors.Is reports its Kind as well as the underlying Err.
+type ParseError struct {
+	Kind   error  // One of ErrShortHeader, ErrBadLength, ErrMissingTagTerminator, ErrExtensionFailed or ErrMalformedHeader
+	Err    error  // The underlying error, e.g., as returned by the ABI extension, may be nil
+	LogId  LogId  // The log the record was read from, LogIdUnknown if not known
+	Offset int64  // Offset of the record in bytes, relative to the first record read by the reader
+	Raw    []byte // A copy of the raw record, possibly truncated to the bytes available
+}
+
+// Error returns a description of self, including kind, log, offset and
+// size of the record.
+func (self *ParseError) Error() string {
+	msg := fmt.Sprintf("%s [log %s, offset %d, %d bytes]", self.Kind, self.LogId, self.Offset, len(self.Raw))
+	if self.Err != nil {
+		msg += ": " + self.Err.Error()
+	}
+
+	return msg
+}
+
+// Unwrap returns kind and underlying error of self.
+func (self *ParseError) Unwrap() []error {
+	if self.Err == nil {
+		return []error{self.Kind}
+	}
+
+	return []error{self.Kind, self.Err}
+}
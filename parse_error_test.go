@@ -0,0 +1,118 @@
+package alog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shortPayloadRecord returns a record whose payload lacks the message.
+func shortPayloadRecord() []byte {
+	record := appendLoggerEntry(nil, &Entry{})[:loggerEntryHeaderSize+2]
+	record[0] = 2
+	return record
+}
+
+func parseErrorOf(t *testing.T, err error) *ParseError {
+	var pe *ParseError
+	require.ErrorAs(t, err, &pe)
+	return pe
+}
+
+func TestParseLoggerRecordReportsErrorKinds(t *testing.T) {
+	record := appendLoggerEntry(nil, &testEntry)
+
+	_, err := parseLoggerRecord(record[:10], nil)
+	assert.True(t, errors.Is(err, ErrShortHeader))
+
+	_, err = parseLoggerRecord(record[:len(record)-1], nil)
+	assert.True(t, errors.Is(err, ErrBadLength))
+
+	_, err = parseLoggerRecord(shortPayloadRecord(), nil)
+	assert.True(t, errors.Is(err, ErrBadLength))
+
+	broken := append([]byte{}, record...)
+	broken[loggerEntryHeaderSize+1+len(testTag)] = 'x'
+	broken = broken[:loggerEntryHeaderSize+1+len(testTag)+1]
+	broken[0] = byte(1 + len(testTag) + 1)
+	_, err = parseLoggerRecord(broken, nil)
+	assert.True(t, errors.Is(err, ErrMissingTagTerminator))
+
+	_, err = parseLoggerRecord(withExtraHeader(&testEntry, []byte{1, 2}), LoggerAbiV2Extension{})
+	assert.True(t, errors.Is(err, ErrExtensionFailed))
+}
+
+func TestParseErrorCarriesRecordAndCause(t *testing.T) {
+	record := withExtraHeader(&testEntry, []byte{1, 2})
+	cause := errors.New("cause")
+
+	_, err := parseLoggerRecord(record, failingAbiExtension{cause})
+	pe := parseErrorOf(t, err)
+
+	assert.Equal(t, ErrExtensionFailed, pe.Kind)
+	assert.Equal(t, LogIdUnknown, pe.LogId)
+	assert.Equal(t, int64(0), pe.Offset)
+	assert.Equal(t, record, pe.Raw)
+	assert.True(t, errors.Is(err, cause))
+	assert.Contains(t, pe.Error(), "cause")
+	assert.Contains(t, pe.Error(), "unknown")
+}
+
+// A failingAbiExtension fails every read with err.
+type failingAbiExtension struct {
+	err error
+}
+
+func (self failingAbiExtension) Read(reader io.Reader) (map[string]interface{}, error) {
+	return nil, self.err
+}
+
+func (self failingAbiExtension) Schema() map[string]reflect.Type {
+	return nil
+}
+
+func TestBinaryReaderReportsOffsetsOfMalformedRecords(t *testing.T) {
+	good := appendLoggerEntry(nil, &testEntry)
+	bad := shortPayloadRecord()
+
+	br := NewBinaryReader(bytes.NewReader(append(append([]byte{}, good...), bad...)), nil)
+
+	_, err := br.ReadNext()
+	require.NoError(t, err)
+
+	_, err = br.ReadNext()
+	pe := parseErrorOf(t, err)
+	assert.Equal(t, int64(len(good)), pe.Offset)
+	assert.Equal(t, bad, pe.Raw)
+
+	_, err = br.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestLenientBinaryReaderSkipsMalformedRecords(t *testing.T) {
+	good := appendLoggerEntry(nil, &testEntry)
+
+	var stream []byte
+	stream = append(stream, good...)
+	stream = append(stream, shortPayloadRecord()...) // Payload too short
+	stream = append(stream, 0xff, 0xff, 0, 0)        // Length beyond the maximum entry size
+	stream = append(stream, make([]byte, loggerEntryHeaderSize+0xffff-4)...)
+	stream = append(stream, good...)
+
+	br := NewBinaryReader(bytes.NewReader(stream), nil)
+	br.SetLenient(true)
+
+	var entries []*Entry
+	for entry, err := br.ReadNext(); err != io.EOF; entry, err = br.ReadNext() {
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+
+	assert.Len(t, entries, 2)
+	assert.Equal(t, uint64(2), br.Skipped())
+}
@@ -0,0 +1,194 @@
+package alog
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultProcRoot is the mount point of the proc filesystem.
+	DefaultProcRoot = "/proc"
+	// DefaultPackagesList is the file the package manager lists installed
+	// packages and their uids in.
+	DefaultPackagesList = "/data/system/packages.list"
+)
+
+// ProcessCacheTTL bounds how long a ProcessResolver trusts a cached process
+// name before looking it up again, as pids are reused over time.
+const ProcessCacheTTL = 10 * time.Second
+
+// androidUsers separates the uids of Android's users, with uid = user * androidUsers + appId.
+const androidUsers = 100000
+
+// androidIds maps the well-known uids of Android's system services to their
+// names, mirroring android_filesystem_config.h.
+var androidIds = map[uint32]string{
+	0:    "root",
+	1000: "system",
+	1001: "radio",
+	1002: "bluetooth",
+	1003: "graphics",
+	1004: "input",
+	1005: "audio",
+	1006: "camera",
+	1007: "log",
+	1010: "wifi",
+	1013: "media",
+	1017: "keystore",
+	1019: "drm",
+	1021: "gps",
+	1036: "logd",
+	1041: "audioserver",
+	1047: "cameraserver",
+	1068: "secure_element",
+	2000: "shell",
+	9999: "nobody",
+}
+
+// A cachedProcess is a process name looked up at a point in time.
+type cachedProcess struct {
+	name string
+	when time.Time
+}
+
+// A ProcessResolver maps pids to the names of processes via
+// <procRoot>/<pid>/cmdline, and uids to the names of packages via the
+// package manager's packages.list. Process names are cached for
+// ProcessCacheTTL, and kept for processes that exited meanwhile, such that
+// entries logged by short-lived processes remain attributable. The packages
+// list is reloaded whenever it changes. A ProcessResolver is safe for
+// concurrent use.
+type ProcessResolver struct {
+	procRoot     string // Mount point of the proc filesystem
+	packagesList string // Path of packages.list
+
+	m         sync.Mutex
+	processes map[int32]cachedProcess // Process names by pid
+	packages  map[uint32]string       // Package names by app id
+	modTime   time.Time               // Modification time of the loaded packages list
+	now       func() time.Time        // Clock used for expiring cached names
+}
+
+// NewProcessResolver returns a ProcessResolver consulting DefaultProcRoot and DefaultPackagesList.
+func NewProcessResolver() *ProcessResolver {
+	return NewProcessResolverForRoot(DefaultProcRoot, DefaultPackagesList)
+}
+
+// NewProcessResolverForRoot returns a ProcessResolver consulting the proc
+// filesystem mounted at procRoot and the packages list at packagesList,
+// e.g., for resolving against a snapshot taken from a device.
+func NewProcessResolverForRoot(procRoot string, packagesList string) *ProcessResolver {
+	return &ProcessResolver{
+		procRoot:     procRoot,
+		packagesList: packagesList,
+		processes:    make(map[int32]cachedProcess),
+		now:          time.Now,
+	}
+}
+
+// ProcessName returns the name of the process with pid, and whether it is known.
+func (self *ProcessResolver) ProcessName(pid int32) (string, bool) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	cached, ok := self.processes[pid]
+	if ok && self.now().Sub(cached.when) < ProcessCacheTTL {
+		return cached.name, true
+	}
+
+	name, found := self.lookupProcess(pid)
+	if !found {
+		return cached.name, ok
+	}
+
+	self.processes[pid] = cachedProcess{name: name, when: self.now()}
+	return name, true
+}
+
+// PackageName returns the name of the package running as uid or, for
+// Android's system services, the name of uid, and whether it is known.
+// Packages sharing a uid are represented by the first one listed.
+func (self *ProcessResolver) PackageName(uid uint32) (string, bool) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.loadPackages()
+
+	appId := uid % androidUsers
+	if name, ok := self.packages[appId]; ok {
+		return name, true
+	}
+
+	name, ok := androidIds[appId]
+	return name, ok
+}
+
+// lookupProcess reads the name of the process with pid from its command
+// line, falling back to comm for kernel threads lacking one.
+func (self *ProcessResolver) lookupProcess(pid int32) (string, bool) {
+	dir := filepath.Join(self.procRoot, strconv.Itoa(int(pid)))
+
+	if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+
+		if name := strings.TrimSpace(string(b)); name != "" {
+			return name, true
+		}
+	}
+
+	if b, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		if name := strings.TrimSpace(string(b)); name != "" {
+			return "[" + name + "]", true
+		}
+	}
+
+	return "", false
+}
+
+// loadPackages (re-)loads the packages list if it changed since it was last
+// loaded. Each line has the form "name uid debuggable dataDir seinfo gids ...".
+func (self *ProcessResolver) loadPackages() {
+	fi, err := os.Stat(self.packagesList)
+	if err != nil || fi.ModTime().Equal(self.modTime) {
+		return
+	}
+
+	f, err := os.Open(self.packagesList)
+	if err != nil {
+		return
+	}
+
+	defer f.Close()
+
+	packages := make(map[uint32]string)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		uid, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := packages[uint32(uid)]; !ok {
+			packages[uint32(uid)] = fields[0]
+		}
+	}
+
+	if s.Err() == nil {
+		self.packages = packages
+		self.modTime = fi.ModTime()
+	}
+}
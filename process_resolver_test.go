@@ -0,0 +1,121 @@
+package alog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withProcRoot populates a fake proc filesystem and packages list in a
+// temporary directory and hands a ProcessResolver consulting them to f.
+func withProcRoot(t *testing.T, f func(dir string, pr *ProcessResolver)) {
+	withTempDir(t, func(dir string) {
+		writeProcFile(t, dir, "42/cmdline", "com.example.app\x00--flag\x00")
+		writeProcFile(t, dir, "2/cmdline", "")
+		writeProcFile(t, dir, "2/comm", "kthreadd\n")
+		writeProcFile(t, dir, "packages.list", "com.example.app 10042 0 /data/user/0/com.example.app default:targetSdkVersion=30 3003\n"+
+			"com.example.shared 10042 0 /data/user/0/com.example.shared default 3003\n")
+
+		f(dir, NewProcessResolverForRoot(dir, filepath.Join(dir, "packages.list")))
+	})
+}
+
+func writeProcFile(t *testing.T, dir string, fn string, content string) {
+	fn = filepath.Join(dir, fn)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0755))
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0644))
+}
+
+func TestProcessResolverResolvesProcessNames(t *testing.T) {
+	withProcRoot(t, func(dir string, pr *ProcessResolver) {
+		name, ok := pr.ProcessName(42)
+		assert.True(t, ok)
+		assert.Equal(t, "com.example.app", name)
+
+		name, ok = pr.ProcessName(2)
+		assert.True(t, ok)
+		assert.Equal(t, "[kthreadd]", name)
+
+		_, ok = pr.ProcessName(4711)
+		assert.False(t, ok)
+	})
+}
+
+func TestProcessResolverCachesProcessNames(t *testing.T) {
+	withProcRoot(t, func(dir string, pr *ProcessResolver) {
+		now := time.Now()
+		pr.now = func() time.Time { return now }
+
+		_, ok := pr.ProcessName(42)
+		require.True(t, ok)
+
+		writeProcFile(t, dir, "42/cmdline", "com.example.other\x00")
+		name, _ := pr.ProcessName(42)
+		assert.Equal(t, "com.example.app", name)
+
+		now = now.Add(ProcessCacheTTL)
+		name, _ = pr.ProcessName(42)
+		assert.Equal(t, "com.example.other", name)
+
+		// Names of exited processes remain available.
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "42")))
+		now = now.Add(ProcessCacheTTL)
+		name, ok = pr.ProcessName(42)
+		assert.True(t, ok)
+		assert.Equal(t, "com.example.other", name)
+	})
+}
+
+func TestProcessResolverResolvesPackageNames(t *testing.T) {
+	withProcRoot(t, func(dir string, pr *ProcessResolver) {
+		name, ok := pr.PackageName(10042)
+		assert.True(t, ok)
+		assert.Equal(t, "com.example.app", name)
+
+		// Secondary users share the app id.
+		name, ok = pr.PackageName(1010042)
+		assert.True(t, ok)
+		assert.Equal(t, "com.example.app", name)
+
+		name, ok = pr.PackageName(1000)
+		assert.True(t, ok)
+		assert.Equal(t, "system", name)
+
+		_, ok = pr.PackageName(10043)
+		assert.False(t, ok)
+
+		writeProcFile(t, dir, "packages.list", "com.example.new 10043 0 /data/user/0/com.example.new default none\n")
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "packages.list"), later, later))
+
+		name, ok = pr.PackageName(10043)
+		assert.True(t, ok)
+		assert.Equal(t, "com.example.new", name)
+	})
+}
+
+func TestFormatRendersModifiers(t *testing.T) {
+	withProcRoot(t, func(dir string, pr *ProcessResolver) {
+		entry := testEntry
+		entry.Message = "first"
+		entry.Ext = map[string]interface{}{ExtUid: uint32(10042)}
+
+		buf := &bytes.Buffer{}
+		FormatThreadtime.RenderWith(buf, &entry, ModifierUid|ModifierProcess, pr)
+		assert.Equal(t, "11-01 12:13:14.015 com.example.app    42/com.example.app    43 I Test    : first\n", buf.String())
+
+		buf.Reset()
+		FormatBrief.RenderWith(buf, &entry, ModifierUid, nil)
+		assert.Equal(t, "I/Test    (10042    42): first\n", buf.String())
+
+		entry.Ext = nil
+		buf.Reset()
+		FormatBrief.RenderWith(buf, &entry, ModifierUid|ModifierProcess, nil)
+		assert.Equal(t, "I/Test    (    -    42): first\n", buf.String())
+	})
+}
@@ -0,0 +1,358 @@
+package alog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pmsgMagic      = 'l' // Magic byte starting every record in the pmsg stream
+	pmsgHeaderSize = 7   // Size of android_pmsg_log_header_t: magic, len, uid and pid
+)
+
+var (
+	// DefaultPstore is the directory the kernel exposes the contents of
+	// persistent RAM surviving a reboot in.
+	DefaultPstore = "/sys/fs/pstore"
+	// DefaultLastKmsg is the file exposing the console output of the previous
+	// boot on kernels predating pstore.
+	DefaultLastKmsg = "/proc/last_kmsg"
+)
+
+// A pstoreSource yields the entries recovered from a single pstore file.
+type pstoreSource interface {
+	next() (*Entry, error)
+}
+
+// A PstoreReader implements Reader, recovering the logs of the previous boot
+// from pstore: kernel messages from the console ramoops text and Android log
+// entries from the pmsg ramoops binary stream. All sources are read in turn.
+//
+// Kernel messages carry their monotonic timestamp relative to the previous
+// boot under key 'monotonic' in their Ext field, with When being the same
+// duration after the epoch. Android log entries carry their log id and the
+// uid of the writer under keys 'lid' and 'uid'.
+type PstoreReader struct {
+	sources []pstoreSource // Remaining sources, the current one first
+	closers []io.Closer    // Files opened by the reader
+	lenient bool           // Whether to skip malformed records
+	skipped uint64         // Number of malformed records skipped
+}
+
+// NewPstoreReader returns a PstoreReader recovering the logs of the previous
+// boot from DefaultPstore or, lacking console ramoops, from DefaultLastKmsg.
+//
+// Returns an error if neither is available.
+func NewPstoreReader() (*PstoreReader, error) {
+	pr, err := NewPstoreReaderForDir(DefaultPstore)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if pr == nil || !pr.hasConsole() {
+		if f, err := os.Open(DefaultLastKmsg); err == nil {
+			if pr == nil {
+				pr = &PstoreReader{}
+			}
+			pr.sources = append([]pstoreSource{newConsoleSource(f)}, pr.sources...)
+			pr.closers = append(pr.closers, f)
+		}
+	}
+
+	if pr == nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+// NewPstoreReaderForDir returns a PstoreReader recovering the logs of the
+// previous boot from the console-ramoops* and pmsg-ramoops* files in dir.
+//
+// Returns an error wrapping os.ErrNotExist if dir contains neither.
+// Returns an error if opening any of the files fails.
+func NewPstoreReaderForDir(dir string) (*PstoreReader, error) {
+	pr := &PstoreReader{}
+
+	for _, pattern := range []string{"console-ramoops*", "pmsg-ramoops*"} {
+		fns, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, fn := range fns {
+			f, err := os.Open(fn)
+			if err != nil {
+				pr.Close()
+				return nil, err
+			}
+
+			pr.closers = append(pr.closers, f)
+			if strings.HasPrefix(filepath.Base(fn), "console") {
+				pr.sources = append(pr.sources, newConsoleSource(f))
+			} else {
+				pr.sources = append(pr.sources, newPmsgSource(f))
+			}
+		}
+	}
+
+	if len(pr.sources) == 0 {
+		return nil, &os.PathError{Op: "open", Path: filepath.Join(dir, "{console,pmsg}-ramoops*"), Err: os.ErrNotExist}
+	}
+
+	return pr, nil
+}
+
+// NewConsoleRamoopsReader returns a PstoreReader parsing the console output
+// read from r, as found in console ramoops or last_kmsg.
+func NewConsoleRamoopsReader(r io.Reader) *PstoreReader {
+	return &PstoreReader{sources: []pstoreSource{newConsoleSource(r)}}
+}
+
+// NewPmsgReader returns a PstoreReader parsing the pmsg binary stream read
+// from r, as found in pmsg ramoops.
+func NewPmsgReader(r io.Reader) *PstoreReader {
+	return &PstoreReader{sources: []pstoreSource{newPmsgSource(r)}}
+}
+
+// Close closes all files opened by self.
+func (self *PstoreReader) Close() error {
+	var errs []error
+	for _, c := range self.closers {
+		errs = append(errs, c.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetDeadline is a noop for PstoreReader.
+func (self *PstoreReader) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetLenient makes self skip malformed pmsg records instead of returning a
+// *ParseError, counting them in Skipped. In both modes, reading resumes with
+// the next intact record.
+func (self *PstoreReader) SetLenient(lenient bool) {
+	self.lenient = lenient
+}
+
+// Skipped returns the number of malformed records skipped by self in lenient mode.
+func (self *PstoreReader) Skipped() uint64 {
+	return self.skipped
+}
+
+// ReadNext returns the next entry recovered from pstore.
+//
+// Returns io.EOF once all sources are exhausted.
+// Returns a *ParseError if a pmsg record is malformed, unless self is lenient.
+// Returns an error if reading fails.
+func (self *PstoreReader) ReadNext() (*Entry, error) {
+	for len(self.sources) > 0 {
+		entry, err := self.sources[0].next()
+		if err == io.EOF {
+			self.sources = self.sources[1:]
+			continue
+		}
+
+		var pe *ParseError
+		if err != nil && self.lenient && errors.As(err, &pe) {
+			self.skipped++
+			continue
+		}
+
+		return entry, err
+	}
+
+	return nil, io.EOF
+}
+
+func (self *PstoreReader) hasConsole() bool {
+	for _, s := range self.sources {
+		if _, ok := s.(*consoleSource); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A consoleSource parses kernel console output, one entry per line of the
+// form "<level>[seconds.micros] message", with level being optional. Lines
+// lacking a timestamp continue the message of the preceding entry.
+type consoleSource struct {
+	s       *bufio.Scanner
+	pending *Entry // Entry waiting for continuation lines
+}
+
+func newConsoleSource(r io.Reader) *consoleSource {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 64*1024)
+	return &consoleSource{s: s}
+}
+
+func (self *consoleSource) next() (*Entry, error) {
+	for self.s.Scan() {
+		entry, ok := parseConsoleLine(self.s.Bytes())
+		if !ok {
+			line := strings.TrimSpace(string(bytes.ToValidUTF8(self.s.Bytes(), []byte("\uFFFD"))))
+			if self.pending != nil && line != "" {
+				self.pending.Message += "\n" + line
+			} else if line != "" {
+				self.pending = &Entry{Priority: PriorityInfo, Tag: kmsgTag, Message: line, Ext: map[string]interface{}{ExtLogId: LogIdKernel}}
+			}
+			continue
+		}
+
+		prev := self.pending
+		self.pending = entry
+		if prev != nil {
+			return prev, nil
+		}
+	}
+
+	if err := self.s.Err(); err != nil {
+		return nil, err
+	}
+
+	if prev := self.pending; prev != nil {
+		self.pending = nil
+		return prev, nil
+	}
+
+	return nil, io.EOF
+}
+
+// parseConsoleLine parses a single line of kernel console output.
+func parseConsoleLine(line []byte) (*Entry, bool) {
+	prio := PriorityInfo
+	if len(line) >= 3 && line[0] == '<' && line[2] == '>' && line[1] >= '0' && line[1] <= '7' {
+		prio = kmsgPriority(int(line[1] - '0'))
+		line = line[3:]
+	}
+
+	if len(line) == 0 || line[0] != '[' {
+		return nil, false
+	}
+
+	end := bytes.IndexByte(line, ']')
+	if end < 0 {
+		return nil, false
+	}
+
+	secs, err := strconv.ParseFloat(string(bytes.TrimSpace(line[1:end])), 64)
+	if err != nil {
+		return nil, false
+	}
+
+	monotonic := time.Duration(secs * float64(time.Second)).Round(time.Microsecond)
+	line = line[end+1:]
+
+	entry := &Entry{
+		When:     NewTimestamp(time.Unix(0, 0).Add(monotonic)),
+		Priority: prio,
+		Tag:      kmsgTag,
+		Ext:      map[string]interface{}{ExtLogId: LogIdKernel, ExtMonotonic: monotonic},
+	}
+
+	// Kernels built with CONFIG_PRINTK_CALLER add the calling thread, e.g., [    T42].
+	if len(line) > 0 && line[0] == '[' {
+		if end := bytes.IndexByte(line, ']'); end >= 0 {
+			if caller, ok := bytes.CutPrefix(bytes.TrimSpace(line[1:end]), []byte("T")); ok {
+				if tid, err := strconv.Atoi(string(caller)); err == nil {
+					entry.Tid = int32(tid)
+					line = line[end+1:]
+				}
+			}
+		}
+	}
+
+	entry.Message = messageString(line)
+	return entry, true
+}
+
+// A pmsgSource parses the pmsg binary stream, consisting of records made
+// up of android_pmsg_log_header_t, android_log_header_t and the payload.
+type pmsgSource struct {
+	r      *bufio.Reader
+	offset int64 // Offset of the next record
+}
+
+// newPmsgSource returns a pmsgSource reading from r, buffering enough to
+// peek at records of up to maxEntrySize bytes.
+func newPmsgSource(r io.Reader) *pmsgSource {
+	return &pmsgSource{r: bufio.NewReaderSize(r, maxEntrySize)}
+}
+
+func (self *pmsgSource) next() (*Entry, error) {
+	hdr, err := self.r.Peek(pmsgHeaderSize + logdHeaderSize)
+	if err == io.EOF && len(hdr) == 0 {
+		return nil, io.EOF
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	size := 0
+	if len(hdr) >= pmsgHeaderSize {
+		size = int(binary.LittleEndian.Uint16(hdr[1:]))
+	}
+
+	if len(hdr) < pmsgHeaderSize+logdHeaderSize || hdr[0] != pmsgMagic || size < len(hdr)+3 {
+		return nil, self.resync(ErrShortHeader, hdr)
+	}
+
+	// Lengths beyond any entry the logger accepts stem from corrupted
+	// records, e.g., after a watchdog reset, and exceed the buffer.
+	if size > maxEntrySize {
+		return nil, self.resync(ErrBadLength, hdr)
+	}
+
+	record, err := self.r.Peek(size)
+	if err != nil && err != io.EOF {
+		return nil, err
+	} else if len(record) < size {
+		return nil, self.resync(ErrBadLength, record)
+	}
+
+	entry := &Entry{
+		Pid: int32(binary.LittleEndian.Uint16(record[5:])),
+		Tid: int32(binary.LittleEndian.Uint16(record[8:])),
+		When: Timestamp{
+			Seconds:     int32(binary.LittleEndian.Uint32(record[10:])),
+			Nanoseconds: int32(binary.LittleEndian.Uint32(record[14:])),
+		},
+		Ext: map[string]interface{}{
+			ExtLogId: LogId(record[7]),
+			ExtUid:   uint32(binary.LittleEndian.Uint16(record[3:])),
+		},
+	}
+
+	if err := parsePayload(record[pmsgHeaderSize+logdHeaderSize:], entry, nil, false); err != nil {
+		return nil, self.resync(err, record)
+	}
+
+	self.r.Discard(size)
+	self.offset += int64(size)
+	return entry, nil
+}
+
+// resync returns a *ParseError of kind for record and skips ahead to the
+// next byte that might start a record.
+func (self *pmsgSource) resync(kind error, record []byte) error {
+	pe := &ParseError{Kind: kind, LogId: LogIdUnknown, Offset: self.offset, Raw: append([]byte(nil), record...)}
+
+	n := 1
+	if i := bytes.IndexByte(record[1:], pmsgMagic); i >= 0 {
+		n += i
+	} else {
+		n = len(record)
+	}
+
+	self.r.Discard(n)
+	self.offset += int64(n)
+	return pe
+}
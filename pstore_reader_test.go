@@ -0,0 +1,185 @@
+package alog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConsole = "[    0.000000] Booting Linux on physical CPU 0x0\n" +
+	"<3>[  120.500000][  T321] watchdog: BUG: soft lockup - CPU#0 stuck for 22s!\n" +
+	"Call trace:\n" +
+	" dump_backtrace+0x0/0x1c0\n"
+
+// appendPmsgRecord appends entry to b, framed as written to pmsg by liblog.
+func appendPmsgRecord(b []byte, id LogId, uid uint16, entry *Entry) []byte {
+	payload := 1 + len(entry.Tag) + 1 + len(entry.Message) + 1
+
+	b = append(b, pmsgMagic)
+	b = binary.LittleEndian.AppendUint16(b, uint16(pmsgHeaderSize+logdHeaderSize+payload))
+	b = binary.LittleEndian.AppendUint16(b, uid)
+	b = binary.LittleEndian.AppendUint16(b, uint16(entry.Pid))
+	b = append(b, byte(id))
+	b = binary.LittleEndian.AppendUint16(b, uint16(entry.Tid))
+	b = binary.LittleEndian.AppendUint32(b, uint32(entry.When.Seconds))
+	b = binary.LittleEndian.AppendUint32(b, uint32(entry.When.Nanoseconds))
+	b = append(b, byte(entry.Priority))
+	b = append(b, entry.Tag...)
+	b = append(b, 0)
+	b = append(b, entry.Message...)
+	return append(b, 0)
+}
+
+func TestPstoreReaderParsesConsoleRamoops(t *testing.T) {
+	pr := NewConsoleRamoopsReader(strings.NewReader(testConsole))
+
+	entry, err := pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, PriorityInfo, entry.Priority)
+	assert.Equal(t, Tag("kernel"), entry.Tag)
+	assert.Equal(t, "Booting Linux on physical CPU 0x0", entry.Message)
+	assert.Equal(t, time.Duration(0), entry.Ext[ExtMonotonic])
+
+	entry, err = pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, PriorityError, entry.Priority)
+	assert.Equal(t, int32(321), entry.Tid)
+	assert.Equal(t, 120500*time.Millisecond, entry.Ext[ExtMonotonic])
+	assert.Equal(t, "watchdog: BUG: soft lockup - CPU#0 stuck for 22s!\nCall trace:\ndump_backtrace+0x0/0x1c0", entry.Message)
+
+	_, err = pr.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPstoreReaderParsesPmsg(t *testing.T) {
+	var stream []byte
+	stream = appendPmsgRecord(stream, LogIdMain, 1000, &testEntry)
+	stream = appendPmsgRecord(stream, LogIdSystem, 1001, &Entry{Pid: 7, Priority: PriorityFatal, Tag: "watchdog", Message: "last words"})
+
+	pr := NewPmsgReader(bytes.NewReader(stream))
+
+	entry, err := pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Pid, entry.Pid)
+	assert.Equal(t, testEntry.Tid, entry.Tid)
+	assert.Equal(t, testEntry.When, entry.When)
+	assert.Equal(t, testEntry.Tag, entry.Tag)
+	assert.Equal(t, testEntry.Message, entry.Message)
+	assert.Equal(t, LogIdMain, entry.Ext[ExtLogId])
+	assert.Equal(t, uint32(1000), entry.Ext[ExtUid])
+
+	entry, err = pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, "last words", entry.Message)
+	assert.Equal(t, LogIdSystem, entry.Ext[ExtLogId])
+
+	_, err = pr.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPstoreReaderResyncsAfterCorruptPmsgRecords(t *testing.T) {
+	record := appendPmsgRecord(nil, LogIdMain, 0, &testEntry)
+
+	var stream []byte
+	stream = append(stream, record...)
+	stream = append(stream, 0xde, 0xad, 0xbe, 0xef)
+	stream = append(stream, record...)
+	stream = append(stream, record[:30]...)
+
+	pr := NewPmsgReader(bytes.NewReader(stream))
+
+	_, err := pr.ReadNext()
+	require.NoError(t, err)
+
+	_, err = pr.ReadNext()
+	var pe *ParseError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, int64(len(record)), pe.Offset)
+
+	entry, err := pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Message, entry.Message)
+
+	_, err = pr.ReadNext()
+	assert.True(t, errors.Is(err, ErrBadLength))
+
+	_, err = pr.ReadNext()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestLenientPstoreReaderSkipsCorruptPmsgRecords(t *testing.T) {
+	record := appendPmsgRecord(nil, LogIdMain, 0, &testEntry)
+	stream := append(append([]byte{0xde, 0xad}, record...), record[:30]...)
+
+	pr := NewPmsgReader(bytes.NewReader(stream))
+	pr.SetLenient(true)
+
+	_, err := pr.ReadNext()
+	require.NoError(t, err)
+
+	_, err = pr.ReadNext()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, uint64(2), pr.Skipped())
+}
+
+func TestPstoreReaderResyncsAfterOversizedPmsgRecords(t *testing.T) {
+	record := appendPmsgRecord(nil, LogIdMain, 0, &testEntry)
+
+	corrupt := append([]byte{pmsgMagic, 0xff, 0xff}, make([]byte, pmsgHeaderSize+logdHeaderSize-3)...)
+	stream := append(corrupt, record...)
+
+	pr := NewPmsgReader(bytes.NewReader(stream))
+
+	_, err := pr.ReadNext()
+	assert.True(t, errors.Is(err, ErrBadLength))
+
+	entry, err := pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Message, entry.Message)
+
+	pr = NewPmsgReader(bytes.NewReader(stream))
+	pr.SetLenient(true)
+
+	entry, err = pr.ReadNext()
+	require.NoError(t, err)
+	assert.Equal(t, testEntry.Message, entry.Message)
+	assert.Equal(t, uint64(1), pr.Skipped())
+}
+
+func TestPstoreReaderReadsAllFilesInDir(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "console-ramoops-0"), []byte(testConsole), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pmsg-ramoops-0"), appendPmsgRecord(nil, LogIdMain, 0, &testEntry), 0644))
+
+		pr, err := NewPstoreReaderForDir(dir)
+		require.NoError(t, err)
+		defer pr.Close()
+
+		var entries []*Entry
+		for entry, err := pr.ReadNext(); err != io.EOF; entry, err = pr.ReadNext() {
+			require.NoError(t, err)
+			entries = append(entries, entry)
+		}
+
+		require.Len(t, entries, 3)
+		assert.Equal(t, Tag("kernel"), entries[0].Tag)
+		assert.Equal(t, testEntry.Tag, entries[2].Tag)
+	})
+}
+
+func TestPstoreReaderFailsForEmptyDir(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		_, err := NewPstoreReaderForDir(dir)
+		assert.True(t, errors.Is(err, os.ErrNotExist))
+	})
+}
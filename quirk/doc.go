@@ -1,3 +1,12 @@
 // Package quirk bundles vendor-specific quirks
 // for reading/writing log entries.
+//
+// Quirks register themselves by name, together with the device properties
+// identifying affected devices and the number of header bytes they consume.
+// Tools list them via All, select them via ByName and alog.DetectAbiExtension
+// picks them via Lookup.
+//
+// Most quirks merely add fixed-size fields to the header. Those are declared as
+// a Go struct with `alog:"name"` field tags and read by a StructAbiExtension,
+// whose Size doubles as the quirk's HeaderSize.
 package quirk
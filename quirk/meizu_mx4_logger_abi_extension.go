@@ -1,10 +1,18 @@
 package quirk
 
 import (
-	"encoding/binary"
 	"io"
+	"reflect"
 )
 
+// meizuMx4Header is the vendor-specific part of the v2 header
+// written by the Meizu MX4 kernel logger.
+type meizuMx4Header struct {
+	Tz int32 `alog:"tz"`
+}
+
+var meizuMx4 = MustStructAbiExtension(meizuMx4Header{}, nil)
+
 // A MeizuMx4LoggerAbiExtension reads the additional timezone field
 // as defined in the kernel source code at:
 //   https://github.com/meizuosc/m75/blob/master/kernel/drivers/staging/android/logger.h.
@@ -15,10 +23,20 @@ type MeizuMx4LoggerAbiExtension struct {
 //
 // Returns an error if reading from reader fails.
 func (self MeizuMx4LoggerAbiExtension) Read(reader io.Reader) (map[string]interface{}, error) {
-	tz := int32(-1)
-	if err := binary.Read(reader, binary.LittleEndian, &tz); err != nil {
-		return nil, err
-	}
+	return meizuMx4.Read(reader)
+}
+
+// Schema declares the tz field.
+func (self MeizuMx4LoggerAbiExtension) Schema() map[string]reflect.Type {
+	return meizuMx4.Schema()
+}
 
-	return map[string]interface{}{"tz": tz}, nil
+func init() {
+	Register(Quirk{
+		Name:        "meizu-mx4",
+		Description: "Meizu MX4, adding a timezone field (tz) to the v2 header",
+		Properties:  map[string]string{"ro.product.model": "MX4"},
+		HeaderSize:  meizuMx4.Size(),
+		Extension:   MeizuMx4LoggerAbiExtension{},
+	})
 }
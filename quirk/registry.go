@@ -0,0 +1,112 @@
+package quirk
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// An AbiExtension reads vendor-specific fields from the header of a kernel
+// logger entry. It mirrors alog.LoggerAbiExtension, such that package quirk
+// does not depend on package alog.
+type AbiExtension interface {
+	Read(reader io.Reader) (map[string]interface{}, error)
+	Schema() map[string]reflect.Type
+}
+
+// A Quirk describes a vendor-specific extension of the kernel logger's v2 ABI.
+type Quirk struct {
+	Name        string            // Unique name of the quirk, e.g. meizu-mx4
+	Description string            // Human-readable description of the quirk
+	Properties  map[string]string // Device properties identifying affected devices, e.g. ro.product.model
+	HeaderSize  int               // Number of header bytes consumed by Extension
+	Extension   AbiExtension      // Reads the vendor-specific header fields preceding euid
+}
+
+// Matches returns true if all Properties of self are present in props.
+func (self Quirk) Matches(props map[string]string) bool {
+	for k, v := range self.Properties {
+		if props[k] != v {
+			return false
+		}
+	}
+
+	return len(self.Properties) > 0
+}
+
+// registry holds all known quirks by name.
+var registry = struct {
+	sync.Mutex
+	quirks map[string]Quirk
+}{quirks: make(map[string]Quirk)}
+
+// Register makes q known under q.Name. Quirks are meant to register
+// themselves in an init function.
+//
+// Panics if q lacks a name or extension, or if a quirk with the same name is already registered.
+func Register(q Quirk) {
+	if q.Name == "" || q.Extension == nil {
+		panic("quirk: Register requires a name and an extension")
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, dup := registry.quirks[q.Name]; dup {
+		panic(fmt.Sprintf("quirk: Register called twice for quirk %s", q.Name))
+	}
+
+	registry.quirks[q.Name] = q
+}
+
+// All returns all registered quirks, sorted by name.
+func All() []Quirk {
+	registry.Lock()
+	defer registry.Unlock()
+
+	quirks := make([]Quirk, 0, len(registry.quirks))
+	for _, q := range registry.quirks {
+		quirks = append(quirks, q)
+	}
+
+	sort.Slice(quirks, func(i, j int) bool { return quirks[i].Name < quirks[j].Name })
+	return quirks
+}
+
+// Names returns the names of all registered quirks, sorted.
+func Names() []string {
+	var names []string
+	for _, q := range All() {
+		names = append(names, q.Name)
+	}
+
+	return names
+}
+
+// ByName returns the Quirk registered under name.
+func ByName(name string) (Quirk, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	q, ok := registry.quirks[name]
+	return q, ok
+}
+
+// Lookup returns the first registered Quirk, in order of names, matching
+// the device properties props. If headerSize is not negative, only quirks
+// consuming exactly headerSize header bytes are considered.
+func Lookup(props map[string]string, headerSize int) (Quirk, bool) {
+	for _, q := range All() {
+		if headerSize >= 0 && q.HeaderSize != headerSize {
+			continue
+		}
+
+		if q.Matches(props) {
+			return q, true
+		}
+	}
+
+	return Quirk{}, false
+}
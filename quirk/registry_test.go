@@ -0,0 +1,54 @@
+package quirk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mx4Properties = map[string]string{"ro.product.model": "MX4", "ro.hardware": "mt6595"}
+
+func TestMeizuMx4IsRegistered(t *testing.T) {
+	assert.Contains(t, Names(), "meizu-mx4")
+
+	q, ok := ByName("meizu-mx4")
+	assert.True(t, ok)
+	assert.Equal(t, 4, q.HeaderSize)
+	assert.Equal(t, MeizuMx4LoggerAbiExtension{}, q.Extension)
+
+	_, ok = ByName("unknown")
+	assert.False(t, ok)
+}
+
+func TestLookupMatchesPropertiesAndHeaderSize(t *testing.T) {
+	q, ok := Lookup(mx4Properties, -1)
+	assert.True(t, ok)
+	assert.Equal(t, "meizu-mx4", q.Name)
+
+	_, ok = Lookup(mx4Properties, 4)
+	assert.True(t, ok)
+
+	_, ok = Lookup(mx4Properties, 8)
+	assert.False(t, ok)
+
+	_, ok = Lookup(map[string]string{"ro.product.model": "Nexus 4"}, -1)
+	assert.False(t, ok)
+}
+
+func TestLookupRequiresAllPropertiesToMatch(t *testing.T) {
+	q := Quirk{Properties: map[string]string{"ro.product.model": "MX4", "ro.hardware": "mt6595"}}
+
+	assert.True(t, q.Matches(mx4Properties))
+	assert.False(t, q.Matches(map[string]string{"ro.product.model": "MX4"}))
+	assert.False(t, Quirk{}.Matches(mx4Properties))
+}
+
+func TestRegisterPanicsForDuplicateNames(t *testing.T) {
+	assert.Panics(t, func() {
+		Register(Quirk{Name: "meizu-mx4", Extension: MeizuMx4LoggerAbiExtension{}})
+	})
+
+	assert.Panics(t, func() {
+		Register(Quirk{Name: "no-extension"})
+	})
+}
@@ -0,0 +1,132 @@
+package quirk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A structField maps a field of the struct read by a StructAbiExtension to
+// its key in the extension map.
+type structField struct {
+	key   string
+	index int
+}
+
+// A StructAbiExtension reads vendor-specific header fields as declared by a
+// Go struct. Fields tagged with `alog:"name"` are placed into the extension map
+// under key name, untagged and blank (_) fields are read but skipped. All fields must
+// have a fixed size, e.g.:
+//
+//	type header struct {
+//		Tz  int32   `alog:"tz"`
+//		_   [2]byte
+//		Cpu uint16  `alog:"cpu"`
+//	}
+type StructAbiExtension struct {
+	t      reflect.Type     // The struct type read from the header
+	order  binary.ByteOrder // Byte order of the header fields
+	size   int              // Size of t on the wire
+	fields []structField    // Tagged fields of t
+}
+
+// NewStructAbiExtension returns a new StructAbiExtension reading structs of
+// the type of prototype, which is either a struct or a pointer to a struct, in
+// byte order. If order is nil, binary.LittleEndian is used.
+//
+// Returns an error if prototype is not a struct, if any of its fields has no
+// fixed size, if a tagged field is unexported or if a tag is used twice.
+func NewStructAbiExtension(prototype interface{}, order binary.ByteOrder) (*StructAbiExtension, error) {
+	t := reflect.TypeOf(prototype)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is not a struct", t)
+	}
+
+	size := binary.Size(reflect.New(t).Interface())
+	if size < 0 {
+		return nil, fmt.Errorf("%v has fields without a fixed size", t)
+	}
+
+	if order == nil {
+		order = binary.LittleEndian
+	}
+
+	self := &StructAbiExtension{t: t, order: order, size: size}
+	seen := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "_" {
+			continue
+		}
+
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("Field %s of %v is unexported", f.Name, t)
+		}
+
+		key := f.Tag.Get("alog")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		if seen[key] {
+			return nil, fmt.Errorf("Key %s is used by more than one field of %v", key, t)
+		}
+
+		seen[key] = true
+		self.fields = append(self.fields, structField{key: key, index: i})
+	}
+
+	return self, nil
+}
+
+// MustStructAbiExtension is like NewStructAbiExtension but panics if the
+// extension cannot be created. It is meant for initializing quirks in
+// package-level variables.
+func MustStructAbiExtension(prototype interface{}, order binary.ByteOrder) *StructAbiExtension {
+	self, err := NewStructAbiExtension(prototype, order)
+	if err != nil {
+		panic(fmt.Sprintf("quirk: %s", err))
+	}
+
+	return self
+}
+
+// Size returns the number of header bytes consumed by self, suitable for
+// Quirk.HeaderSize.
+func (self *StructAbiExtension) Size() int {
+	return self.size
+}
+
+// Read reads a single struct from reader, returning all tagged fields
+// in the extension map.
+//
+// Returns an error if reading from reader fails.
+func (self *StructAbiExtension) Read(reader io.Reader) (map[string]interface{}, error) {
+	v := reflect.New(self.t)
+	if err := binary.Read(reader, self.order, v.Interface()); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(self.fields))
+	for _, f := range self.fields {
+		result[f.key] = v.Elem().Field(f.index).Interface()
+	}
+
+	return result, nil
+}
+
+// Schema declares all tagged fields together with their Go types.
+func (self *StructAbiExtension) Schema() map[string]reflect.Type {
+	result := make(map[string]reflect.Type, len(self.fields))
+	for _, f := range self.fields {
+		result[f.key] = self.t.Field(f.index).Type
+	}
+
+	return result
+}
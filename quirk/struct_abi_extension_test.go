@@ -0,0 +1,78 @@
+package quirk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type multiFieldHeader struct {
+	Tz      int32 `alog:"tz"`
+	_       [2]byte
+	Cpu     uint16 `alog:"cpu"`
+	Ignored uint8
+	Flags   [3]byte `alog:"flags"`
+}
+
+func TestStructAbiExtensionReadsTaggedFields(t *testing.T) {
+	ext, err := NewStructAbiExtension(&multiFieldHeader{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 12, ext.Size())
+
+	raw := []byte{0x10, 0x0e, 0x00, 0x00, 0xff, 0xff, 0x03, 0x00, 0x07, 1, 2, 3}
+	m, err := ext.Read(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tz": int32(3600), "cpu": uint16(3), "flags": [3]byte{1, 2, 3}}, m)
+
+	assert.Equal(t, map[string]reflect.Type{
+		"tz":    reflect.TypeOf(int32(0)),
+		"cpu":   reflect.TypeOf(uint16(0)),
+		"flags": reflect.TypeOf([3]byte{}),
+	}, ext.Schema())
+}
+
+func TestStructAbiExtensionHonoursByteOrder(t *testing.T) {
+	ext, err := NewStructAbiExtension(multiFieldHeader{}, binary.BigEndian)
+	require.NoError(t, err)
+
+	raw := []byte{0x00, 0x00, 0x0e, 0x10, 0xff, 0xff, 0x00, 0x03, 0x07, 1, 2, 3}
+	m, err := ext.Read(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3600), m["tz"])
+	assert.Equal(t, uint16(3), m["cpu"])
+}
+
+func TestStructAbiExtensionFailsOnShortHeader(t *testing.T) {
+	ext := MustStructAbiExtension(multiFieldHeader{}, nil)
+
+	_, err := ext.Read(bytes.NewReader([]byte{1, 2, 3}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestNewStructAbiExtensionRejectsInvalidStructs(t *testing.T) {
+	_, err := NewStructAbiExtension(42, nil)
+	assert.Error(t, err)
+
+	_, err = NewStructAbiExtension(struct {
+		Name string `alog:"name"`
+	}{}, nil)
+	assert.Error(t, err)
+
+	_, err = NewStructAbiExtension(struct {
+		a int32 `alog:"a"`
+	}{}, nil)
+	assert.Error(t, err)
+
+	_, err = NewStructAbiExtension(struct {
+		A int32 `alog:"a"`
+		B int32 `alog:"a"`
+	}{}, nil)
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { MustStructAbiExtension(42, nil) })
+}
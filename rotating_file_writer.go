@@ -0,0 +1,258 @@
+package alog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A SyncPolicy determines when a RotatingFileWriter flushes its file to stable storage.
+type SyncPolicy int
+
+const (
+	SyncNever    SyncPolicy = iota // Leave flushing to the operating system
+	SyncOnRotate                   // Sync a file before rotating or closing it
+	SyncAlways                     // Sync after every entry
+)
+
+// A RotationConfig bundles the options of a RotatingFileWriter, mirroring
+// logcat's -f, -r and -n options.
+type RotationConfig struct {
+	Format    Format           // Format used for rendering entries
+	Modifiers Modifier         // Modifiers adjusting Format
+	Resolver  *ProcessResolver // Resolves process and package names for Modifiers, might be nil
+	MaxSize   int64            // Rotate once a file would exceed MaxSize bytes, 0 disables rotation
+	MaxFiles  int              // Number of rotated files to keep, logcat defaults to 4, at least 1 if MaxSize is set
+	Compress  bool             // Whether to gzip rotated files
+	Sync      SyncPolicy       // When to sync the current file to stable storage
+}
+
+// A RotatingFileWriter renders Entries to a file, rotating it to numbered
+// siblings fn.1 ... fn.MaxFiles (with suffix .gz if compressed) once it
+// exceeds a configured size.
+type RotatingFileWriter struct {
+	fn     string         // The path of the current file
+	config RotationConfig // Rendering and rotation options
+	f      *os.File       // The current file, nil if reopening it after a failed rotation failed
+	closed bool           // Whether Close was called
+	size   int64          // Bytes in the current file
+	buf    bytes.Buffer   // Reused for rendering individual entries
+}
+
+// NewRotatingFileWriter opens fn for appending, creating it if necessary, and
+// returns a RotatingFileWriter configured by config.
+//
+// Returns an error if MaxSize is set without keeping any rotated files.
+// Returns an error if opening fn fails.
+func NewRotatingFileWriter(fn string, config RotationConfig) (*RotatingFileWriter, error) {
+	if config.MaxSize > 0 && config.MaxFiles < 1 {
+		return nil, fmt.Errorf("Rotating %s requires keeping at least one rotated file", fn)
+	}
+
+	self := &RotatingFileWriter{fn: fn, config: config}
+	if err := self.open(); err != nil {
+		return nil, err
+	}
+
+	return self, nil
+}
+
+// open opens the current file, picking up the size of existing content.
+func (self *RotatingFileWriter) open() error {
+	f, err := os.OpenFile(self.fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	self.f = f
+	self.size = fi.Size()
+	return nil
+}
+
+// rotatedName returns the name of the i-th rotated file.
+func (self *RotatingFileWriter) rotatedName(i int, compressed bool) string {
+	if compressed {
+		return fmt.Sprintf("%s.%d.gz", self.fn, i)
+	}
+
+	return fmt.Sprintf("%s.%d", self.fn, i)
+}
+
+// shift renames the i-th rotated file to the i+1-th one, considering both
+// compressed and uncompressed variants.
+func (self *RotatingFileWriter) shift(i int) error {
+	for _, compressed := range []bool{false, true} {
+		err := os.Rename(self.rotatedName(i, compressed), self.rotatedName(i+1, compressed))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compress gzips the file fn to fn.gz, removing fn on success.
+func compress(fn string) error {
+	in, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := os.OpenFile(fn+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+
+	if err == nil {
+		err = out.Sync()
+	}
+
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(fn + ".gz")
+		return err
+	}
+
+	return os.Remove(fn)
+}
+
+// Rotate closes the current file, shifts all rotated files by one, dropping
+// the oldest, and starts a new current file. With MaxFiles 0, the content of
+// the current file is discarded. If rotating fails, self keeps on appending
+// to the current file.
+//
+// Returns os.ErrClosed if self was closed.
+// Returns an error if any of the file operations fails.
+func (self *RotatingFileWriter) Rotate() (err error) {
+	if self.closed {
+		return os.ErrClosed
+	}
+
+	defer func() {
+		if self.f == nil {
+			if oerr := self.open(); err == nil {
+				err = oerr
+			}
+		}
+	}()
+
+	if err := self.close(); err != nil {
+		return err
+	}
+
+	if self.config.MaxFiles > 0 {
+		for _, compressed := range []bool{false, true} {
+			err := os.Remove(self.rotatedName(self.config.MaxFiles, compressed))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		for i := self.config.MaxFiles - 1; i > 0; i-- {
+			if err := self.shift(i); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Rename(self.fn, self.rotatedName(1, false)); err != nil {
+			return err
+		}
+
+		if self.config.Compress {
+			if err := compress(self.rotatedName(1, false)); err != nil {
+				return err
+			}
+		}
+	} else if err := os.Remove(self.fn); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WriteEntry renders entry to the current file, rotating it first if
+// adding entry would exceed the configured maximum size.
+//
+// Returns os.ErrClosed if self was closed.
+// Returns an error if rendering, rotating or syncing fails.
+func (self *RotatingFileWriter) WriteEntry(entry *Entry) error {
+	if self.closed {
+		return os.ErrClosed
+	}
+
+	if self.f == nil {
+		if err := self.open(); err != nil {
+			return err
+		}
+	}
+
+	self.buf.Reset()
+	self.config.Format.RenderWith(&self.buf, entry, self.config.Modifiers, self.config.Resolver)
+
+	if self.config.MaxSize > 0 && self.size > 0 && self.size+int64(self.buf.Len()) > self.config.MaxSize {
+		if err := self.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := self.f.Write(self.buf.Bytes())
+	self.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if self.config.Sync == SyncAlways {
+		return self.f.Sync()
+	}
+
+	return nil
+}
+
+// Sync commits the current file to stable storage.
+func (self *RotatingFileWriter) Sync() error {
+	return self.f.Sync()
+}
+
+// close syncs the current file according to the configured policy and
+// closes it, if still open.
+func (self *RotatingFileWriter) close() error {
+	if self.f == nil {
+		return nil
+	}
+
+	f := self.f
+	self.f = nil
+
+	if self.config.Sync != SyncNever {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+// Close closes the current file. Writing to self afterwards fails.
+func (self *RotatingFileWriter) Close() error {
+	self.closed = true
+	return self.close()
+}
@@ -0,0 +1,180 @@
+package alog
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A sliceReader implements Reader, handing out entries until exhausted
+// and returning io.EOF afterwards.
+type sliceReader struct {
+	entries []*Entry
+}
+
+func (self *sliceReader) Close() error {
+	return nil
+}
+
+func (self *sliceReader) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (self *sliceReader) ReadNext() (*Entry, error) {
+	if len(self.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	entry := self.entries[0]
+	self.entries = self.entries[1:]
+	return entry, nil
+}
+
+func withTempDir(t *testing.T, f func(dir string)) {
+	dir, err := ioutil.TempDir("", "alog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	f(dir)
+}
+
+func TestRotatingFileWriterWritesEntriesInFormat(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "log")
+
+		rfw, err := NewRotatingFileWriter(fn, RotationConfig{Format: FormatRaw})
+		require.NoError(t, err)
+
+		reader := &sliceReader{entries: []*Entry{&testEntry, &testEntry}}
+		n, err := CopyEntries(rfw, reader)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		require.NoError(t, rfw.Close())
+
+		b, err := ioutil.ReadFile(fn)
+		require.NoError(t, err)
+		assert.Equal(t, "first\nsecond\nfirst\nsecond\n", string(b))
+	})
+}
+
+func TestRotatingFileWriterRotatesBySizeAndCount(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "log")
+		line := int64(len("first\nsecond\n"))
+
+		rfw, err := NewRotatingFileWriter(fn, RotationConfig{Format: FormatRaw, MaxSize: 2 * line, MaxFiles: 2, Sync: SyncOnRotate})
+		require.NoError(t, err)
+
+		for i := 0; i < 7; i++ {
+			require.NoError(t, rfw.WriteEntry(&testEntry))
+		}
+		require.NoError(t, rfw.Close())
+
+		for _, name := range []string{"log", "log.1", "log.2"} {
+			fi, err := os.Stat(filepath.Join(dir, name))
+			require.NoError(t, err)
+			assert.True(t, fi.Size() <= 2*line)
+		}
+
+		_, err = os.Stat(filepath.Join(dir, "log.3"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestRotatingFileWriterCompressesRotatedFiles(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "log")
+
+		rfw, err := NewRotatingFileWriter(fn, RotationConfig{Format: FormatRaw, MaxSize: 1, MaxFiles: 4, Compress: true})
+		require.NoError(t, err)
+
+		require.NoError(t, rfw.WriteEntry(&testEntry))
+		require.NoError(t, rfw.WriteEntry(&testEntry))
+		require.NoError(t, rfw.Close())
+
+		f, err := os.Open(fn + ".1.gz")
+		require.NoError(t, err)
+		defer f.Close()
+
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+
+		b, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, "first\nsecond\n", string(b))
+
+		_, err = os.Stat(fn + ".1")
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestRotatingFileWriterAppendsToExistingFile(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "log")
+		require.NoError(t, ioutil.WriteFile(fn, []byte(strings.Repeat("x", 10)), 0644))
+
+		rfw, err := NewRotatingFileWriter(fn, RotationConfig{Format: FormatRaw, MaxSize: 12, MaxFiles: 1})
+		require.NoError(t, err)
+		require.NoError(t, rfw.WriteEntry(&testEntry))
+		require.NoError(t, rfw.Close())
+
+		b, err := ioutil.ReadFile(fn + ".1")
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("x", 10), string(b))
+	})
+}
+
+func TestRotatingFileWriterKeepsWritingAfterFailedRotation(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "log")
+
+		// A non-empty directory in place of the oldest rotated file cannot be removed.
+		require.NoError(t, os.MkdirAll(filepath.Join(fn+".1", "busy"), 0755))
+
+		rfw, err := NewRotatingFileWriter(fn, RotationConfig{Format: FormatRaw, MaxSize: 1, MaxFiles: 1})
+		require.NoError(t, err)
+
+		require.NoError(t, rfw.WriteEntry(&testEntry))
+		assert.Error(t, rfw.WriteEntry(&testEntry))
+
+		require.NoError(t, os.RemoveAll(fn+".1"))
+		require.NoError(t, rfw.WriteEntry(&testEntry))
+		require.NoError(t, rfw.Close())
+
+		b, err := ioutil.ReadFile(fn + ".1")
+		require.NoError(t, err)
+		assert.Equal(t, "first\nsecond\n", string(b))
+	})
+}
+
+func TestRotatingFileWriterFailsAfterClose(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		fn := filepath.Join(dir, "log")
+
+		rfw, err := NewRotatingFileWriter(fn, RotationConfig{Format: FormatRaw, MaxSize: 1, MaxFiles: 1})
+		require.NoError(t, err)
+		require.NoError(t, rfw.Close())
+		require.NoError(t, os.Remove(fn))
+
+		assert.Equal(t, os.ErrClosed, rfw.WriteEntry(&testEntry))
+		assert.Equal(t, os.ErrClosed, rfw.Rotate())
+
+		_, err = os.Stat(fn)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestRotatingFileWriterRequiresRotatedFiles(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		_, err := NewRotatingFileWriter(filepath.Join(dir, "log"), RotationConfig{Format: FormatRaw, MaxSize: 1})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,305 @@
+package alog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStreamBacklog is the number of entries buffered per client of a
+// StreamHandler before the client is considered slow and evicted.
+const DefaultStreamBacklog = 256
+
+// A StreamConfig bundles the options of a StreamHandler.
+type StreamConfig struct {
+	Backlog int // Entries buffered per client before evicting it, DefaultStreamBacklog if 0
+}
+
+// A streamClient is a single HTTP client following the stream.
+type streamClient struct {
+	entries chan *Entry // Entries waiting to be sent, closed once the client is done
+	evicted bool        // Whether the client was evicted for falling behind
+}
+
+// A StreamHandler implements http.Handler, serving live entries read from a
+// single underlying Reader to any number of clients. Every client receives
+// all entries read after connecting, selected by the query parameters:
+//
+//	filterspec  logcat filterspecs, e.g., ActivityManager:I *:S, may be repeated
+//	buffer      logs to include, e.g., main,system or all, defaults to all
+//	format      ndjson, sse or any logcat format, e.g., threadtime
+//
+// ndjson streams entries as newline-delimited JSON, sse as Server-Sent
+// Events of type entry carrying the same JSON objects, logcat formats as
+// rendered text. Without format, clients accepting text/event-stream
+// receive sse, all others ndjson.
+//
+// Clients falling behind by more than Backlog entries are evicted, ending
+// their response, such that they never stall the underlying Reader or other
+// clients. SSE clients receive an evicted event first.
+type StreamHandler struct {
+	reader Reader
+	config StreamConfig
+
+	m       sync.Mutex
+	clients map[*streamClient]struct{} // Clients currently following the stream
+	evicted uint64                     // Number of clients evicted so far
+	done    bool                       // Whether reading from reader ended
+	err     error                      // The error that ended reading
+}
+
+// NewStreamHandler returns a StreamHandler serving the entries read from
+// reader, which it starts reading from immediately after clearing the
+// deadline of reader. Errors returned by reader other than *ParseError,
+// including ErrReadTimeout, end all streams.
+func NewStreamHandler(reader Reader, config StreamConfig) *StreamHandler {
+	if config.Backlog == 0 {
+		config.Backlog = DefaultStreamBacklog
+	}
+
+	self := &StreamHandler{reader: reader, config: config, clients: make(map[*streamClient]struct{})}
+	go self.pump()
+	return self
+}
+
+// Close closes the underlying Reader, ending all streams.
+func (self *StreamHandler) Close() error {
+	return self.reader.Close()
+}
+
+// Clients returns the number of clients currently following the stream.
+func (self *StreamHandler) Clients() int {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return len(self.clients)
+}
+
+// Evicted returns the number of clients evicted for falling behind.
+func (self *StreamHandler) Evicted() uint64 {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return self.evicted
+}
+
+// ServeHTTP streams entries to a single client until the client goes away,
+// is evicted or reading from the underlying Reader ends.
+func (self *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter, err := NewFilter(q["filterspec"]...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids, err := parseStreamBuffers(q["buffer"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = "ndjson"
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			format = "sse"
+		}
+	}
+
+	var text Format
+	switch format {
+	case "ndjson", "json":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case "sse":
+		w.Header().Set("Content-Type", "text/event-stream")
+	default:
+		if text, err = ParseFormat(format); err != nil || text == FormatBinary {
+			http.Error(w, fmt.Sprintf("Unsupported format: %s", format), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	client, err := self.register()
+	if client == nil {
+		http.Error(w, fmt.Sprintf("Stream ended: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	defer self.unregister(client)
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry, ok := <-client.entries:
+			if !ok {
+				if format == "sse" && self.wasEvicted(client) {
+					fmt.Fprint(w, "event: evicted\ndata: Client fell behind the stream\n\n")
+				}
+				return
+			}
+
+			if id, ok := entry.LogId(); (ok && !ids[id]) || (!ok && !ids[LogIdMain]) || !filter.Match(entry) {
+				continue
+			}
+
+			buf.Reset()
+			if err := encodeStreamEntry(&buf, entry, format, text); err != nil {
+				continue
+			}
+
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return
+			}
+
+			if flusher != nil && len(client.entries) == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// pump reads entries from the underlying Reader and hands them to all
+// clients, evicting those that fell behind.
+func (self *StreamHandler) pump() {
+	// A deadline set before handing reader to us would make every read time out.
+	err := self.reader.SetDeadline(time.Time{})
+	for err == nil {
+		var entry *Entry
+		entry, err = self.reader.ReadNext()
+
+		var pe *ParseError
+		if errors.As(err, &pe) {
+			err = nil
+			continue
+		} else if err != nil {
+			break
+		}
+
+		self.m.Lock()
+		for client := range self.clients {
+			select {
+			case client.entries <- entry:
+			default:
+				client.evicted = true
+				self.evicted++
+				delete(self.clients, client)
+				close(client.entries)
+			}
+		}
+		self.m.Unlock()
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.done, self.err = true, err
+	for client := range self.clients {
+		delete(self.clients, client)
+		close(client.entries)
+	}
+}
+
+// register adds a new client, returning nil and the error that ended
+// reading if reading already ended.
+func (self *StreamHandler) register() (*streamClient, error) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if self.done {
+		return nil, self.err
+	}
+
+	client := &streamClient{entries: make(chan *Entry, self.config.Backlog)}
+	self.clients[client] = struct{}{}
+	return client, nil
+}
+
+// unregister removes client if it is still registered.
+func (self *StreamHandler) unregister(client *streamClient) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if _, ok := self.clients[client]; ok {
+		delete(self.clients, client)
+		close(client.entries)
+	}
+}
+
+func (self *StreamHandler) wasEvicted(client *streamClient) bool {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return client.evicted
+}
+
+// parseStreamBuffers parses the buffer query parameters, each naming one
+// or more comma-separated logs, defaulting to all logs.
+func parseStreamBuffers(values []string) (map[LogId]bool, error) {
+	all := []LogId{LogIdMain, LogIdRadio, LogIdEvents, LogIdSystem, LogIdCrash, LogIdKernel}
+	if len(values) == 0 {
+		values = []string{"all"}
+	}
+
+	ids := make(map[LogId]bool)
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name == "all" {
+				for _, id := range all {
+					ids[id] = true
+				}
+				continue
+			}
+
+			id, err := ParseLogId(name)
+			if err != nil {
+				return nil, err
+			}
+			ids[id] = true
+		}
+	}
+
+	return ids, nil
+}
+
+// encodeStreamEntry appends entry to buf in format, rendering it in text
+// for logcat formats.
+func encodeStreamEntry(buf *bytes.Buffer, entry *Entry, format string, text Format) error {
+	switch format {
+	case "ndjson", "json", "sse":
+		b, err := entry.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		if format == "sse" {
+			buf.WriteString("event: entry\ndata: ")
+			buf.Write(b)
+			buf.WriteString("\n\n")
+		} else {
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+	default:
+		text.Render(buf, entry)
+	}
+
+	return nil
+}
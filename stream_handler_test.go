@@ -0,0 +1,228 @@
+package alog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A chanReader implements Reader, handing out the entries sent to it and
+// returning io.EOF once closed. Reads time out immediately once the
+// deadline passed.
+type chanReader struct {
+	entries  chan *Entry
+	once     sync.Once
+	m        sync.Mutex
+	deadline time.Time
+}
+
+func newChanReader() *chanReader {
+	return &chanReader{entries: make(chan *Entry)}
+}
+
+func (self *chanReader) Close() error {
+	self.once.Do(func() { close(self.entries) })
+	return nil
+}
+
+func (self *chanReader) SetDeadline(t time.Time) error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.deadline = t
+	return nil
+}
+
+func (self *chanReader) ReadNext() (*Entry, error) {
+	self.m.Lock()
+	deadline := self.deadline
+	self.m.Unlock()
+
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return nil, ErrReadTimeout
+	}
+
+	entry, ok := <-self.entries
+	if !ok {
+		return nil, io.EOF
+	}
+
+	return entry, nil
+}
+
+// withStreamHandler serves a StreamHandler reading from a chanReader and
+// hands both the reader and the server's URL to f.
+func withStreamHandler(t *testing.T, config StreamConfig, f func(cr *chanReader, sh *StreamHandler, url string)) {
+	cr := newChanReader()
+	sh := NewStreamHandler(cr, config)
+	defer sh.Close()
+
+	s := httptest.NewServer(sh)
+	defer s.Close()
+
+	f(cr, sh, s.URL)
+}
+
+// waitForClients waits until n clients follow the stream of sh.
+func waitForClients(t *testing.T, sh *StreamHandler, n int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for sh.Clients() != n {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for clients")
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func streamEntry(tag Tag, prio Priority, id LogId, msg string) *Entry {
+	return &Entry{Pid: 42, Tid: 43, Priority: prio, Tag: tag, Message: msg, Ext: map[string]interface{}{ExtLogId: id}}
+}
+
+func TestStreamHandlerFansOutNDJSON(t *testing.T) {
+	withStreamHandler(t, StreamConfig{}, func(cr *chanReader, sh *StreamHandler, url string) {
+		var readers []*bufio.Reader
+		for i := 0; i < 2; i++ {
+			resp, err := http.Get(url + "?filterspec=Test:W&filterspec=*:S&buffer=main,system")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+			readers = append(readers, bufio.NewReader(resp.Body))
+		}
+
+		waitForClients(t, sh, 2)
+
+		cr.entries <- streamEntry("Test", PriorityInfo, LogIdMain, "too verbose")
+		cr.entries <- streamEntry("Other", PriorityError, LogIdMain, "other tag")
+		cr.entries <- streamEntry("Test", PriorityError, LogIdRadio, "other buffer")
+		cr.entries <- streamEntry("Test", PriorityError, LogIdSystem, "match")
+
+		for _, r := range readers {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+
+			var entry Entry
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			assert.Equal(t, "match", entry.Message)
+			assert.Equal(t, LogIdSystem, entry.Ext[ExtLogId])
+		}
+	})
+}
+
+func TestStreamHandlerServesSSEAndText(t *testing.T) {
+	withStreamHandler(t, StreamConfig{}, func(cr *chanReader, sh *StreamHandler, url string) {
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/event-stream")
+
+		sse, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer sse.Body.Close()
+		assert.Equal(t, "text/event-stream", sse.Header.Get("Content-Type"))
+
+		text, err := http.Get(url + "?format=brief")
+		require.NoError(t, err)
+		defer text.Body.Close()
+		assert.Equal(t, "text/plain; charset=utf-8", text.Header.Get("Content-Type"))
+
+		waitForClients(t, sh, 2)
+		cr.entries <- streamEntry("Test", PriorityInfo, LogIdMain, "first")
+
+		r := bufio.NewReader(sse.Body)
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "event: entry\n", line)
+
+		line, err = r.ReadString('\n')
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(line, `data: {"time":`))
+		assert.True(t, strings.Contains(line, `"message":"first"`))
+
+		line, err = bufio.NewReader(text.Body).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "I/Test    (   42): first\n", line)
+	})
+}
+
+func TestStreamHandlerEvictsSlowClients(t *testing.T) {
+	withStreamHandler(t, StreamConfig{Backlog: 1}, func(cr *chanReader, sh *StreamHandler, url string) {
+		resp, err := http.Get(url + "?format=sse")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		waitForClients(t, sh, 1)
+
+		// Entries pile up once the socket buffers are full, as the client does not read.
+		msg := strings.Repeat("x", 16*1024)
+		for i := 0; sh.Evicted() == 0; i++ {
+			require.True(t, i < 64*1024, "Client was not evicted")
+			cr.entries <- streamEntry("Test", PriorityInfo, LogIdMain, msg)
+		}
+
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(b), "event: evicted\ndata: Client fell behind the stream\n\n"))
+		assert.EqualValues(t, 1, sh.Evicted())
+		assert.Equal(t, 0, sh.Clients())
+	})
+}
+
+func TestStreamHandlerClearsDeadlineOfReader(t *testing.T) {
+	cr := newChanReader()
+	require.NoError(t, cr.SetDeadline(time.Now().Add(-time.Second)))
+
+	sh := NewStreamHandler(cr, StreamConfig{})
+	defer sh.Close()
+
+	s := httptest.NewServer(sh)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "?format=raw")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	waitForClients(t, sh, 1)
+	cr.entries <- streamEntry("Test", PriorityInfo, LogIdMain, "after deadline")
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "after deadline\n", line)
+}
+
+func TestStreamHandlerRejectsBadQueries(t *testing.T) {
+	withStreamHandler(t, StreamConfig{}, func(cr *chanReader, sh *StreamHandler, url string) {
+		for _, q := range []string{"?filterspec=Test:X", "?buffer=fancy", "?format=binary", "?format=fancy"} {
+			resp, err := http.Get(url + q)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, q)
+		}
+	})
+}
+
+func TestStreamHandlerEndsStreamsWithReader(t *testing.T) {
+	withStreamHandler(t, StreamConfig{}, func(cr *chanReader, sh *StreamHandler, url string) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		waitForClients(t, sh, 1)
+		require.NoError(t, sh.Close())
+
+		_, err = io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		resp, err = http.Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	})
+}
@@ -0,0 +1,286 @@
+package alog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A SyslogFormat selects the syslog protocol messages are encoded in.
+type SyslogFormat int
+
+const (
+	SyslogRFC5424 SyslogFormat = iota // <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+	SyslogRFC3164                     // <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+)
+
+// Defaults of SyslogConfig.
+const (
+	DefaultSyslogBuffer = 1024        // Max number of messages buffered while disconnected
+	DefaultSyslogRetry  = time.Second // Min time between reconnection attempts
+)
+
+const (
+	syslogFacilityUser = 1            // Facility of user-level messages
+	syslogSdId         = "alog@32473" // SD-ID of the structured data element carrying Entry details
+)
+
+// ErrSyslogUndelivered is returned if buffered messages cannot be delivered
+// to the syslog server.
+var ErrSyslogUndelivered = errors.New("Failed to deliver buffered messages to the syslog server")
+
+// A SyslogConfig bundles the options of a SyslogSink.
+type SyslogConfig struct {
+	Network  string        // udp, tcp, unix (stream) or unixgram
+	Address  string        // Address of the syslog server, e.g., host:514 or /dev/log
+	Format   SyslogFormat  // Protocol messages are encoded in
+	Facility int           // Syslog facility, user-level messages (1) if 0
+	Hostname string        // Hostname put into messages, os.Hostname if empty
+	Buffer   int           // Max number of messages buffered while disconnected, DefaultSyslogBuffer if 0
+	Retry    time.Duration // Min time between reconnection attempts, DefaultSyslogRetry if 0
+	Timeout  time.Duration // Timeout for connecting and sending, none if 0
+}
+
+// A SyslogSink forwards Entries to a syslog server over UDP, TCP or a unix
+// socket. Stream transports frame messages by octet counting (RFC 6587),
+// datagram transports send one message per datagram.
+//
+// Messages are buffered while the server is unreachable, reconnecting at
+// most once per Retry interval. Once the buffer is full, the oldest messages
+// are dropped and counted in Dropped. A SyslogSink is safe for concurrent use.
+type SyslogSink struct {
+	config SyslogConfig
+	stream bool // Whether the transport requires framing
+
+	m       sync.Mutex
+	conn    net.Conn     // Connection to the server, nil while disconnected
+	queue   [][]byte     // Encoded messages waiting for delivery, oldest first
+	retry   time.Time    // Earliest time of the next connection attempt
+	dropped uint64       // Number of messages dropped due to a full buffer
+	buf     bytes.Buffer // Reused for encoding individual entries
+}
+
+// NewSyslogSink returns a SyslogSink forwarding entries as configured by config.
+// Connecting is deferred to the first entry, such that an unreachable server
+// does not prevent creating the sink.
+//
+// Returns an error if config names an unsupported network.
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	var stream bool
+	switch config.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		stream = true
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("Unsupported syslog network: %s", config.Network)
+	}
+
+	if config.Facility == 0 {
+		config.Facility = syslogFacilityUser
+	}
+
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+
+	if config.Buffer == 0 {
+		config.Buffer = DefaultSyslogBuffer
+	}
+
+	if config.Retry == 0 {
+		config.Retry = DefaultSyslogRetry
+	}
+
+	return &SyslogSink{config: config, stream: stream}, nil
+}
+
+// WriteEntry encodes entry and sends it to the server together with all
+// buffered messages. If the server is unreachable, the message is buffered
+// for a later attempt.
+//
+// Returns nil if entry was either sent or buffered.
+func (self *SyslogSink) WriteEntry(entry *Entry) error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.buf.Reset()
+	self.encode(&self.buf, entry)
+
+	if len(self.queue) >= self.config.Buffer {
+		self.queue = self.queue[1:]
+		self.dropped++
+	}
+
+	self.queue = append(self.queue, append([]byte(nil), self.buf.Bytes()...))
+	self.flush(false)
+	return nil
+}
+
+// Flush sends all buffered messages, connecting to the server regardless of
+// the Retry interval.
+//
+// Returns ErrSyslogUndelivered wrapping the cause if messages remain buffered.
+func (self *SyslogSink) Flush() error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return self.flush(true)
+}
+
+// Pending returns the number of buffered messages awaiting delivery.
+func (self *SyslogSink) Pending() int {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return len(self.queue)
+}
+
+// Dropped returns the number of messages dropped due to a full buffer.
+func (self *SyslogSink) Dropped() uint64 {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return self.dropped
+}
+
+// Close tries to deliver buffered messages and closes the connection to the server.
+//
+// Returns ErrSyslogUndelivered wrapping the cause if messages remain undelivered.
+func (self *SyslogSink) Close() error {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	err := self.flush(true)
+	if self.conn != nil {
+		self.conn.Close()
+		self.conn = nil
+	}
+
+	return err
+}
+
+// flush sends buffered messages, connecting to the server if necessary and
+// either forced or permitted by the Retry interval.
+func (self *SyslogSink) flush(force bool) error {
+	for len(self.queue) > 0 {
+		if self.conn == nil {
+			if !force && time.Now().Before(self.retry) {
+				return ErrSyslogUndelivered
+			}
+
+			conn, err := net.DialTimeout(self.config.Network, self.config.Address, self.config.Timeout)
+			if err != nil {
+				self.retry = time.Now().Add(self.config.Retry)
+				return fmt.Errorf("%w: %w", ErrSyslogUndelivered, err)
+			}
+			self.conn = conn
+		}
+
+		if self.config.Timeout > 0 {
+			self.conn.SetWriteDeadline(time.Now().Add(self.config.Timeout))
+		}
+
+		msg := self.queue[0]
+		if self.stream {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+
+		if _, err := self.conn.Write(msg); err != nil {
+			self.conn.Close()
+			self.conn = nil
+			self.retry = time.Now().Add(self.config.Retry)
+			return fmt.Errorf("%w: %w", ErrSyslogUndelivered, err)
+		}
+
+		self.queue[0] = nil
+		self.queue = self.queue[1:]
+	}
+
+	return nil
+}
+
+// encode appends entry to buf in the configured format.
+func (self *SyslogSink) encode(buf *bytes.Buffer, entry *Entry) {
+	pri := self.config.Facility*8 + syslogSeverity(entry.Priority)
+	hostname := syslogField(self.config.Hostname, 255)
+
+	if self.config.Format == SyslogRFC3164 {
+		tag := strings.Map(func(r rune) rune {
+			if r == ':' || r == '[' || r == ']' {
+				return '_'
+			}
+			return r
+		}, syslogField(string(entry.Tag), 32))
+
+		fmt.Fprintf(buf, "<%d>%s %s %s[%d]: %s", pri, entry.When.Time().Format(time.Stamp), hostname, tag, entry.Pid, entry.Message)
+		return
+	}
+
+	when := entry.When.Time().UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	fmt.Fprintf(buf, "<%d>1 %s %s %s %d - [%s tid=\"%d\"", pri, when, hostname, syslogField(string(entry.Tag), 48), entry.Pid, syslogSdId, entry.Tid)
+
+	if euid, ok := entry.Euid(); ok {
+		fmt.Fprintf(buf, " euid=\"%d\"", euid)
+	}
+
+	if uid, ok := ExtValue[uint32](entry, ExtUid); ok {
+		fmt.Fprintf(buf, " uid=\"%d\"", uid)
+	}
+
+	if id, ok := entry.LogId(); ok {
+		fmt.Fprintf(buf, " lid=\"%s\"", syslogParamValue(id.String()))
+	}
+
+	buf.WriteString("] ")
+	buf.WriteString(entry.Message)
+}
+
+// syslogSeverity maps prio to a syslog severity.
+func syslogSeverity(prio Priority) int {
+	switch prio {
+	case PriorityFatal:
+		return 2 // Critical
+	case PriorityError:
+		return 3 // Error
+	case PriorityWarn:
+		return 4 // Warning
+	case PriorityInfo:
+		return 6 // Informational
+	case PriorityUnknown:
+		return 5 // Notice
+	default:
+		return 7 // Debug
+	}
+}
+
+// syslogField restricts s to at most max printable US-ASCII characters, as
+// required for header fields, returning the nil value "-" for empty strings.
+func syslogField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+// syslogParamValue escapes the characters '"', '\' and ']' in a PARAM-VALUE.
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
@@ -0,0 +1,159 @@
+package alog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogTestEntry = Entry{
+	Pid:      42,
+	Tid:      43,
+	When:     NewTimestamp(time.Date(2015, time.November, 1, 12, 13, 14, 15000000, time.UTC)),
+	Priority: PriorityError,
+	Tag:      "Test Tag",
+	Message:  "first\nsecond",
+	Ext:      map[string]interface{}{ExtEuid: uint32(1000), ExtLogId: LogIdSystem},
+}
+
+// readOctetCounted reads a single message framed by octet counting from r.
+func readOctetCounted(t *testing.T, r *bufio.Reader) string {
+	prefix, err := r.ReadString(' ')
+	require.NoError(t, err)
+
+	n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+	require.NoError(t, err)
+
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestSyslogSinkEncodesRFC5424(t *testing.T) {
+	sink, err := NewSyslogSink(SyslogConfig{Network: "udp", Hostname: "device"})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	sink.encode(buf, &syslogTestEntry)
+	assert.Equal(t, `<11>1 2015-11-01T12:13:14.015000Z device Test_Tag 42 - [alog@32473 tid="43" euid="1000" lid="system"] first`+"\nsecond", buf.String())
+}
+
+func TestSyslogSinkEncodesRFC3164(t *testing.T) {
+	sink, err := NewSyslogSink(SyslogConfig{Network: "udp", Hostname: "device", Format: SyslogRFC3164, Facility: 16})
+	require.NoError(t, err)
+
+	entry := syslogTestEntry
+	entry.Tag = "a:b"
+	entry.Priority = PriorityInfo
+
+	buf := &bytes.Buffer{}
+	sink.encode(buf, &entry)
+	assert.Equal(t, "<134>"+entry.When.Time().Format(time.Stamp)+" device a_b[42]: first\nsecond", buf.String())
+}
+
+func TestSyslogSinkForwardsOverUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewSyslogSink(SyslogConfig{Network: "udp", Address: conn.LocalAddr().String(), Hostname: "device"})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	n, err := CopyEntries(sink, &sliceReader{entries: []*Entry{&syslogTestEntry, &syslogTestEntry}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	b := make([]byte, 4096)
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(b)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(b[:n]), "<11>1 "))
+		assert.True(t, strings.HasSuffix(string(b[:n]), "first\nsecond"))
+	}
+}
+
+func TestSyslogSinkFramesByOctetCountingOverStreams(t *testing.T) {
+	withTempDir(t, func(dir string) {
+		for _, network := range []string{"tcp", "unix"} {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(dir, "syslog")
+			}
+
+			l, err := net.Listen(network, address)
+			require.NoError(t, err)
+
+			sink, err := NewSyslogSink(SyslogConfig{Network: network, Address: l.Addr().String(), Hostname: "device"})
+			require.NoError(t, err)
+
+			require.NoError(t, sink.WriteEntry(&syslogTestEntry))
+			require.NoError(t, sink.WriteEntry(&syslogTestEntry))
+			require.NoError(t, sink.Close())
+
+			conn, err := l.Accept()
+			require.NoError(t, err)
+
+			r := bufio.NewReader(conn)
+			for i := 0; i < 2; i++ {
+				msg := readOctetCounted(t, r)
+				assert.True(t, strings.HasSuffix(msg, "] first\nsecond"), network)
+			}
+
+			conn.Close()
+			l.Close()
+		}
+	})
+}
+
+func TestSyslogSinkBuffersWhileDisconnected(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	l.Close()
+
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Address: address, Buffer: 2, Retry: time.Hour})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		entry := syslogTestEntry
+		entry.Message = strconv.Itoa(i)
+		require.NoError(t, sink.WriteEntry(&entry))
+	}
+
+	assert.Equal(t, 2, sink.Pending())
+	assert.EqualValues(t, 1, sink.Dropped())
+	assert.ErrorIs(t, sink.Flush(), ErrSyslogUndelivered)
+
+	l, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.NoError(t, sink.Flush())
+	assert.Equal(t, 0, sink.Pending())
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	assert.True(t, strings.HasSuffix(readOctetCounted(t, r), "] 1"))
+	assert.True(t, strings.HasSuffix(readOctetCounted(t, r), "] 2"))
+	require.NoError(t, sink.Close())
+}
+
+func TestNewSyslogSinkRejectsUnknownNetworks(t *testing.T) {
+	_, err := NewSyslogSink(SyslogConfig{Network: "carrier-pigeon"})
+	assert.Error(t, err)
+}
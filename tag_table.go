@@ -0,0 +1,49 @@
+package alog
+
+import "sync"
+
+// A TagTable interns tags, such that readers hand out the same Tag for
+// recurring tags instead of allocating a new string per entry. A TagTable is
+// safe for concurrent use and can be shared among readers.
+type TagTable struct {
+	m    sync.Mutex
+	tags map[string]Tag
+	max  int
+}
+
+// NewTagTable returns a new TagTable holding at most max tags. Tags beyond
+// max are not interned, but allocated per entry. If max is 0, the table
+// is unbounded.
+func NewTagTable(max int) *TagTable {
+	return &TagTable{tags: make(map[string]Tag), max: max}
+}
+
+// Intern returns the Tag corresponding to b, adding it to self if necessary.
+// A nil TagTable returns a newly allocated Tag.
+func (self *TagTable) Intern(b []byte) Tag {
+	if self == nil {
+		return Tag(b)
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	if tag, ok := self.tags[string(b)]; ok {
+		return tag
+	}
+
+	tag := Tag(b)
+	if self.max == 0 || len(self.tags) < self.max {
+		self.tags[string(tag)] = tag
+	}
+
+	return tag
+}
+
+// Len returns the number of tags interned by self.
+func (self *TagTable) Len() int {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	return len(self.tags)
+}
@@ -0,0 +1,29 @@
+package alog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagTableInternsTags(t *testing.T) {
+	tags := NewTagTable(0)
+
+	assert.Equal(t, Tag("first"), tags.Intern([]byte("first")))
+	assert.Equal(t, Tag("first"), tags.Intern([]byte("first")))
+	assert.Equal(t, Tag("second"), tags.Intern([]byte("second")))
+	assert.Equal(t, 2, tags.Len())
+}
+
+func TestTagTableHonoursLimit(t *testing.T) {
+	tags := NewTagTable(1)
+
+	tags.Intern([]byte("first"))
+	assert.Equal(t, Tag("second"), tags.Intern([]byte("second")))
+	assert.Equal(t, 1, tags.Len())
+}
+
+func TestNilTagTableAllocatesTags(t *testing.T) {
+	var tags *TagTable
+	assert.Equal(t, Tag("tag"), tags.Intern([]byte("tag")))
+}
@@ -0,0 +1,130 @@
+package alog
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Patterns matching a single line of logcat's text formats.
+var (
+	threadtimePattern = regexp.MustCompile(`^(\d\d-\d\d \d\d:\d\d:\d\d\.\d+)\s+(\d+)\s+(\d+) ([VDIWEFS]) (.*?)\s*: ?(.*)$`)
+	timePattern       = regexp.MustCompile(`^(\d\d-\d\d \d\d:\d\d:\d\d\.\d+) ([VDIWEFS])/(.*?)\(\s*(\d+)\): ?(.*)$`)
+	briefPattern      = regexp.MustCompile(`^([VDIWEFS])/(.*?)\(\s*(\d+)\): ?(.*)$`)
+)
+
+// A TextReader implements Reader, parsing log entries from text in logcat's
+// threadtime, time or brief format, as written by logcat or TextWriter.
+// Consecutive lines sharing time, pid, tid, priority and tag are merged into
+// a single multi-line entry. Lines in other formats, e.g., the
+// "--------- beginning of main" separators, are skipped.
+//
+// The text formats lack the year, which is assumed to be the current one
+// unless that places an entry in the future.
+type TextReader struct {
+	r       io.Reader      // The text we parse entries from
+	s       *bufio.Scanner // Splits the text into lines
+	pending *Entry         // Entry waiting for further lines of its message
+	now     time.Time      // Reference for completing dates lacking the year
+}
+
+// NewTextReader returns a TextReader parsing the text read from r.
+func NewTextReader(r io.Reader) *TextReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 64*1024)
+	return &TextReader{r: r, s: s, now: time.Now()}
+}
+
+// Close closes the underlying reader if it implements io.Closer.
+func (self *TextReader) Close() error {
+	if c, ok := self.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// SetDeadline is a noop for TextReader.
+func (self *TextReader) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// ReadNext returns the next entry parsed from the text.
+//
+// Returns io.EOF at the end of the text.
+// Returns an error if reading fails.
+func (self *TextReader) ReadNext() (*Entry, error) {
+	for self.s.Scan() {
+		entry, ok := self.parseLine(strings.TrimRight(self.s.Text(), "\r"))
+		if !ok {
+			continue
+		}
+
+		if p := self.pending; p != nil && p.When == entry.When && p.Pid == entry.Pid && p.Tid == entry.Tid && p.Priority == entry.Priority && p.Tag == entry.Tag {
+			p.Message += "\n" + entry.Message
+			continue
+		}
+
+		prev := self.pending
+		self.pending = entry
+		if prev != nil {
+			return prev, nil
+		}
+	}
+
+	if err := self.s.Err(); err != nil {
+		return nil, err
+	}
+
+	if prev := self.pending; prev != nil {
+		self.pending = nil
+		return prev, nil
+	}
+
+	return nil, io.EOF
+}
+
+// parseLine parses line in one of the supported formats.
+func (self *TextReader) parseLine(line string) (*Entry, bool) {
+	var when, pid, tid, prio, tag, msg string
+
+	if m := threadtimePattern.FindStringSubmatch(line); m != nil {
+		when, pid, tid, prio, tag, msg = m[1], m[2], m[3], m[4], m[5], m[6]
+	} else if m := timePattern.FindStringSubmatch(line); m != nil {
+		when, prio, tag, pid, msg = m[1], m[2], m[3], m[4], m[5]
+	} else if m := briefPattern.FindStringSubmatch(line); m != nil {
+		prio, tag, pid, msg = m[1], m[2], m[3], m[4]
+	} else {
+		return nil, false
+	}
+
+	entry := &Entry{Tag: Tag(strings.TrimSpace(tag)), Message: msg}
+	entry.Priority, _ = ParsePriority(prio)
+
+	if n, err := strconv.Atoi(pid); err == nil {
+		entry.Pid = int32(n)
+	}
+
+	if n, err := strconv.Atoi(tid); err == nil {
+		entry.Tid = int32(n)
+	}
+
+	if when != "" {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", strconv.Itoa(self.now.Year())+"-"+when[:strings.IndexByte(when, '.')], time.Local)
+		if err != nil {
+			return nil, false
+		}
+
+		if t.After(self.now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+
+		frac, _ := strconv.ParseFloat("0"+when[strings.IndexByte(when, '.'):], 64)
+		entry.When = NewTimestamp(t.Add(time.Duration(frac * float64(time.Second)).Round(time.Millisecond)))
+	}
+
+	return entry, true
+}
@@ -0,0 +1,61 @@
+package alog
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readText(t *testing.T, text string, now time.Time) []*Entry {
+	tr := NewTextReader(strings.NewReader(text))
+	tr.now = now
+
+	var entries []*Entry
+	for entry, err := tr.ReadNext(); err != io.EOF; entry, err = tr.ReadNext() {
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestTextReaderRoundTripsRenderedEntries(t *testing.T) {
+	now := time.Date(2015, time.December, 31, 0, 0, 0, 0, time.Local)
+
+	for _, format := range []Format{FormatThreadtime, FormatTime} {
+		entries := readText(t, "--------- beginning of main\n"+render(format, &testEntry), now)
+		require.Len(t, entries, 1, format.String())
+
+		assert.Equal(t, testEntry.Pid, entries[0].Pid)
+		assert.Equal(t, testEntry.When, entries[0].When)
+		assert.Equal(t, testEntry.Priority, entries[0].Priority)
+		assert.Equal(t, testEntry.Tag, entries[0].Tag)
+		assert.Equal(t, testEntry.Message, entries[0].Message)
+	}
+
+	entries := readText(t, render(FormatThreadtime, &testEntry), now)
+	assert.Equal(t, testEntry.Tid, entries[0].Tid)
+}
+
+func TestTextReaderParsesBriefFormat(t *testing.T) {
+	entries := readText(t, "W/ActivityManager(  123): Slow operation\nE/Test    ( 7): 42\n", time.Now())
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, PriorityWarn, entries[0].Priority)
+	assert.Equal(t, Tag("ActivityManager"), entries[0].Tag)
+	assert.Equal(t, int32(123), entries[0].Pid)
+	assert.Equal(t, "Slow operation", entries[0].Message)
+	assert.Equal(t, Tag("Test"), entries[1].Tag)
+}
+
+func TestTextReaderAssumesPreviousYearForFutureDates(t *testing.T) {
+	now := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.Local)
+
+	entries := readText(t, render(FormatThreadtime, &testEntry), now)
+	require.Len(t, entries, 1)
+	assert.Equal(t, testEntry.When, entries[0].When)
+}
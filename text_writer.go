@@ -0,0 +1,86 @@
+package alog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A TextWriter implements Writer, rendering log messages in one of logcat's
+// formats to an io.Writer. It is meant to be used on hosts without Android's
+// logging facilities.
+type TextWriter struct {
+	m      sync.Mutex   // Serializes writes to w
+	w      io.Writer    // The destination for formatted log messages
+	c      io.Closer    // Closed when closing the TextWriter, might be nil
+	format Format       // The format log messages are rendered in
+	buf    bytes.Buffer // Reused for formatting individual entries
+}
+
+// NewTextWriter returns a TextWriter sending log messages to w in logcat's
+// threadtime format. Closing the TextWriter does not close w.
+func NewTextWriter(w io.Writer) *TextWriter {
+	return &TextWriter{w: w, format: FormatThreadtime}
+}
+
+// OpenTextWriter returns a TextWriter appending log messages to the file
+// fn, creating it if necessary.
+//
+// Returns an error if opening fn fails.
+func OpenTextWriter(fn string) (*TextWriter, error) {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TextWriter{w: f, c: f, format: FormatThreadtime}, nil
+}
+
+// SetFormat adjusts the format subsequent log messages are rendered in.
+func (self *TextWriter) SetFormat(format Format) {
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.format = format
+}
+
+// Close closes the underlying file if self was created by OpenTextWriter.
+func (self *TextWriter) Close() error {
+	if self.c == nil {
+		return nil
+	}
+
+	return self.c.Close()
+}
+
+// SetDeadline is a noop for TextWriter.
+func (self *TextWriter) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// Write renders a log message with prio, tag and message, stamped with
+// the current time, process and thread id.
+//
+// Returns an error if writing to the underlying io.Writer fails.
+func (self *TextWriter) Write(prio Priority, tag Tag, message string) error {
+	entry := Entry{
+		Pid:      int32(os.Getpid()),
+		Tid:      int32(syscall.Gettid()),
+		When:     NewTimestamp(time.Now()),
+		Priority: prio,
+		Tag:      tag,
+		Message:  message,
+	}
+
+	self.m.Lock()
+	defer self.m.Unlock()
+
+	self.buf.Reset()
+	self.format.Render(&self.buf, &entry)
+
+	_, err := self.w.Write(self.buf.Bytes())
+	return err
+}
@@ -0,0 +1,58 @@
+package alog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var threadtimeLine = regexp.MustCompile(`^\d\d-\d\d \d\d:\d\d:\d\d\.\d{3} +\d+ +\d+ [VDIWEF] .{8}: .*$`)
+
+func TestTextWriterRendersThreadtimeFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := NewTextWriter(buf)
+
+	require.NoError(t, tw.Write(PriorityWarn, testTag, "42"))
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	assert.True(t, threadtimeLine.MatchString(line), line)
+	assert.True(t, strings.HasSuffix(line, " W Test    : 42"), line)
+}
+
+func TestTextWriterHonoursFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tw := NewTextWriter(buf)
+	tw.SetFormat(FormatTag)
+
+	require.NoError(t, tw.Write(PriorityWarn, testTag, "42"))
+	assert.Equal(t, "W/Test    : 42\n", buf.String())
+}
+
+func TestOpenTextWriterAppendsToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "log")
+
+	tw, err := OpenTextWriter(fn)
+	require.NoError(t, err)
+	require.NoError(t, tw.Write(PriorityInfo, testTag, "1"))
+	require.NoError(t, tw.Close())
+
+	tw, err = OpenTextWriter(fn)
+	require.NoError(t, err)
+	require.NoError(t, tw.Write(PriorityInfo, testTag, "2"))
+	require.NoError(t, tw.Close())
+
+	b, err := ioutil.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), "\n"))
+}
@@ -19,3 +19,33 @@ type Writer interface {
 	// Returns an error if writing to the underlying Android logging facilities fails.
 	Write(prio Priority, tag Tag, message string) error
 }
+
+// An EntryWriter consumes Entries, e.g., by persisting or forwarding them.
+type EntryWriter interface {
+	// WriteEntry consumes entry.
+	//
+	// Returns an error if entry cannot be consumed.
+	WriteEntry(entry *Entry) error
+}
+
+// CopyEntries reads Entries from reader and writes them to w until reading
+// fails, returning the number of Entries written.
+//
+// Returns the error that terminated reading, nil if reader hit io.EOF.
+// Returns an error if writing an Entry fails.
+func CopyEntries(w EntryWriter, reader Reader) (int, error) {
+	n := 0
+	for {
+		entry, err := reader.ReadNext()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		if err = w.WriteEntry(entry); err != nil {
+			return n, err
+		}
+		n++
+	}
+}